@@ -0,0 +1,74 @@
+package actor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownPrincipal is attributed changes where the identity responsible is not known, e.g. those detected by
+// reconciliation
+const UnknownPrincipal = "<unknown>"
+
+// Actor identifies who made a change. Where the change was made via impersonation or service account delegation, the
+// full chain of identities is held, so that the person ultimately responsible can be reported.
+type Actor struct {
+	// Principal is the identity the request was authenticated as
+	Principal string `json:"principal"`
+	// ImpersonatedUser is the identity the principal impersonated (e.g. kubectl --as), if any
+	ImpersonatedUser string `json:"impersonatedUser,omitempty"`
+	// Delegates are the identities that delegated to the principal, ordered from the original caller
+	Delegates []string `json:"delegates,omitempty"`
+	// AuthoritySelector is the authority asserted by the principal, if any
+	AuthoritySelector string `json:"authoritySelector,omitempty"`
+	// CallerIP is the IP address the request was made from, if known
+	CallerIP string `json:"callerIp,omitempty"`
+	// UserAgent is the user agent supplied by the client, if known
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// Effective returns the identity the change was made as
+func (a Actor) Effective() string {
+	if a.ImpersonatedUser != "" {
+		return a.ImpersonatedUser
+	}
+	return a.Principal
+}
+
+// OnBehalfOf returns the identity that originally initiated the change, where it differs from the effective identity.
+// An empty string is returned if the change was made directly.
+func (a Actor) OnBehalfOf() string {
+	if len(a.Delegates) > 0 {
+		return a.Delegates[0]
+	}
+	if a.ImpersonatedUser != "" {
+		return a.Principal
+	}
+	return ""
+}
+
+// Chain returns every identity involved in the change, ordered from the original caller to the effective identity
+func (a Actor) Chain() []string {
+	chain := make([]string, 0, len(a.Delegates)+2)
+	chain = append(chain, a.Delegates...)
+	chain = append(chain, a.Principal)
+	if a.ImpersonatedUser != "" {
+		chain = append(chain, a.ImpersonatedUser)
+	}
+	return chain
+}
+
+// String describes the actor in a human-readable form, e.g. "ci-bot@acme.iam.gserviceaccount.com on behalf of
+// alice@acme.com"
+func (a Actor) String() string {
+	onBehalfOf := a.OnBehalfOf()
+	if onBehalfOf == "" {
+		return a.Effective()
+	}
+
+	// Any identities between the original caller and the effective identity are listed as intermediaries
+	chain := a.Chain()
+	if via := chain[1 : len(chain)-1]; len(via) > 0 {
+		return fmt.Sprintf("%s on behalf of %s via %s", a.Effective(), onBehalfOf, strings.Join(via, ", "))
+	}
+	return fmt.Sprintf("%s on behalf of %s", a.Effective(), onBehalfOf)
+}
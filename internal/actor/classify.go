@@ -0,0 +1,106 @@
+package actor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kind classifies the type of actor that made a change
+type Kind string
+
+const (
+	// KindHuman is a person, e.g. using kubectl or the flux CLI directly
+	KindHuman Kind = "human"
+	// KindServiceAccount is a kubernetes or Google Cloud service account not otherwise recognised
+	KindServiceAccount Kind = "serviceAccount"
+	// KindCI is an automated pipeline, e.g. a CI runner or infrastructure as code tooling
+	KindCI Kind = "ci"
+	// KindGitOps is a GitOps controller, e.g. the fluxcd or Argo CD controllers
+	KindGitOps Kind = "gitops"
+	// KindUnknown is an actor whose identity is not known, so cannot be classified
+	KindUnknown Kind = "unknown"
+)
+
+var (
+	// gitOpsPrincipalPattern matches the service accounts the fluxcd and Argo CD controllers run as
+	gitOpsPrincipalPattern = regexp.MustCompile(`^system:serviceaccount:(flux-system|argocd):`)
+	// serviceAccountPattern matches kubernetes and Google Cloud service accounts
+	serviceAccountPattern = regexp.MustCompile(`^system:serviceaccount:|\.gserviceaccount\.com$`)
+	// ciPrincipalPattern matches identities whose name suggests a CI system
+	ciPrincipalPattern = regexp.MustCompile(`(?i)(^|[^a-z])(ci|cd|github|gitlab|jenkins|buildkite|circleci|cloudbuild)([^a-z]|$)`)
+)
+
+// knownClient maps a user agent product name to the client it identifies, and the kind of actor that uses it, if
+// that is implied by the client alone
+type knownClient struct {
+	product string
+	client  string
+	kind    Kind
+}
+
+var knownClients = []knownClient{
+	{product: "flux", client: "flux"},
+	{product: "kubectl", client: "kubectl"},
+	{product: "lens", client: "lens"},
+	{product: "openlens", client: "lens"},
+	{product: "k9s", client: "k9s"},
+	{product: "helm", client: "helm"},
+	{product: "terraform", client: "terraform", kind: KindCI},
+	{product: "pulumi", client: "pulumi", kind: KindCI},
+	{product: "kustomize-controller", client: "kustomize-controller", kind: KindGitOps},
+	{product: "helm-controller", client: "helm-controller", kind: KindGitOps},
+	{product: "source-controller", client: "source-controller", kind: KindGitOps},
+	{product: "argocd-application-controller", client: "argocd", kind: KindGitOps},
+	{product: "argocd-server", client: "argocd", kind: KindGitOps},
+}
+
+// Client returns the name of the client used to make the change (e.g. kubectl, flux, lens, terraform), as derived from
+// the user agent. An empty string is returned if it is not recognised.
+func (a Actor) Client() string {
+	c, ok := a.client()
+	if !ok {
+		return ""
+	}
+	return c.client
+}
+
+// Kind classifies the actor, based on the identities involved and the client used
+func (a Actor) Kind() Kind {
+	if a.Principal == "" || a.Principal == UnknownPrincipal {
+		return KindUnknown
+	}
+
+	c, ok := a.client()
+	if ok && c.kind != "" {
+		return c.kind
+	}
+
+	if gitOpsPrincipalPattern.MatchString(a.Effective()) {
+		return KindGitOps
+	}
+	for _, identity := range a.Chain() {
+		if ciPrincipalPattern.MatchString(identity) && serviceAccountPattern.MatchString(identity) {
+			return KindCI
+		}
+	}
+	if len(a.Delegates) > 0 && !strings.Contains(a.Delegates[0], "@") {
+		return KindCI // Federated identities, e.g. a GitHub Actions OIDC subject
+	}
+	if serviceAccountPattern.MatchString(a.Chain()[0]) {
+		return KindServiceAccount // Nobody further up the chain, so not a person acting via a service account
+	}
+	return KindHuman
+}
+
+// client finds the known client matching a product named by the user agent, e.g. kubectl/v1.29.1 (linux/amd64)
+func (a Actor) client() (knownClient, bool) {
+	for _, token := range strings.Fields(strings.ToLower(a.UserAgent)) {
+		product, _, _ := strings.Cut(token, "/")
+		for _, c := range knownClients {
+			if product == c.product {
+				return c, true
+			}
+		}
+	}
+	return knownClient{}, false
+}
@@ -0,0 +1,85 @@
+package actor
+
+import "testing"
+
+func TestActor_Kind(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  Kind
+	}{
+		{
+			name:  "person",
+			actor: Actor{Principal: "alice@acme.com"},
+			want:  KindHuman,
+		},
+		{
+			name:  "person using kubectl",
+			actor: Actor{Principal: "alice@acme.com", UserAgent: "kubectl/v1.29.1 (linux/amd64) kubernetes/bc401b9"},
+			want:  KindHuman,
+		},
+		{
+			name:  "unknown principal",
+			actor: Actor{Principal: UnknownPrincipal},
+			want:  KindUnknown,
+		},
+		{
+			name:  "empty principal",
+			actor: Actor{},
+			want:  KindUnknown,
+		},
+		{
+			name:  "fluxcd controller",
+			actor: Actor{Principal: "system:serviceaccount:flux-system:kustomize-controller"},
+			want:  KindGitOps,
+		},
+		{
+			name:  "gitops client",
+			actor: Actor{Principal: "alice@acme.com", UserAgent: "argocd-server/v2.10.0"},
+			want:  KindGitOps,
+		},
+		{
+			name:  "infrastructure as code client",
+			actor: Actor{Principal: "alice@acme.com", UserAgent: "Terraform/1.7.0 terraform-provider-kubernetes/2.25.0"},
+			want:  KindCI,
+		},
+		{
+			name:  "ci service account",
+			actor: Actor{Principal: "github-deployer@acme.iam.gserviceaccount.com"},
+			want:  KindCI,
+		},
+		{
+			name:  "ci service account impersonated by a person",
+			actor: Actor{Principal: "alice@acme.com", ImpersonatedUser: "system:serviceaccount:ci:deployer"},
+			want:  KindCI,
+		},
+		{
+			name: "federated identity",
+			actor: Actor{
+				Principal: "deployer@acme.iam.gserviceaccount.com",
+				Delegates: []string{"principal://iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/github/subject/repo:acme/app"},
+			},
+			want: KindCI,
+		},
+		{
+			name:  "service account",
+			actor: Actor{Principal: "system:serviceaccount:platform:operator"},
+			want:  KindServiceAccount,
+		},
+		{
+			name: "person delegating to a service account",
+			actor: Actor{
+				Principal: "operator@acme.iam.gserviceaccount.com",
+				Delegates: []string{"alice@acme.com"},
+			},
+			want: KindHuman,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.actor.Kind(); got != tt.want {
+				t.Errorf("Kind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package auditfilter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// VerbCreate is the API verb used to create a resource
+	VerbCreate = "create"
+	// VerbDelete is the API verb used to delete a resource. Deletions of tracked resources are notified, if watched.
+	VerbDelete = "delete"
+)
+
+var (
+	// DefaultVerbs are the API verbs watched when none are configured
+	DefaultVerbs = []string{"patch", VerbCreate, VerbDelete}
+	// DefaultExcludedPrincipals excludes the fluxcd controllers, which continually patch the resources they reconcile
+	DefaultExcludedPrincipals = []string{`^system:serviceaccount:flux-system:.*-controller$`}
+	// FluxAPIGroups matches the API groups of the fluxcd toolkit, which are always of interest
+	FluxAPIGroups = []string{"*.toolkit.fluxcd.io"}
+)
+
+// Filter determines which audit events are of interest, based on the API verb used, the API group of the resource and
+// the principal that made the request. It is used both to build server-side queries, and to filter events client-side.
+type Filter struct {
+	verbs             []string
+	excludePrincipals []*regexp.Regexp
+	includePrincipals []*regexp.Regexp
+	// apiGroups may be extended after instantiation, as suspendable resource types are discovered
+	apiGroups   []string
+	apiGroupsMu sync.RWMutex
+}
+
+// New instantiates and returns a Filter. Principal patterns are regular expressions (RE2 syntax, as also used by Cloud
+// Logging). A principal is matched if it matches none of the excluded patterns and, when included patterns are
+// supplied, at least one of those. Nil verbs or excluded principals fall back to the defaults. API groups are of
+// interest in addition to those of the fluxcd toolkit, and may be prefixed by a wildcard (e.g. *.example.com).
+func New(verbs, excludePrincipals, includePrincipals, apiGroups []string) (*Filter, error) {
+	if verbs == nil {
+		verbs = DefaultVerbs
+	}
+	if len(verbs) == 0 {
+		return nil, fmt.Errorf("at least one verb must be supplied")
+	}
+	if excludePrincipals == nil {
+		excludePrincipals = DefaultExcludedPrincipals
+	}
+
+	exclude, err := compile(excludePrincipals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid excluded principal: %w", err)
+	}
+	include, err := compile(includePrincipals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid included principal: %w", err)
+	}
+
+	groups := slices.Clone(FluxAPIGroups)
+	for _, group := range apiGroups {
+		if strings.Contains(strings.TrimPrefix(group, "*."), "*") || group == "" {
+			return nil, fmt.Errorf("invalid api group: %q", group)
+		}
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+
+	return &Filter{
+		verbs:             verbs,
+		apiGroups:         groups,
+		excludePrincipals: exclude,
+		includePrincipals: include,
+	}, nil
+}
+
+// Default returns a filter with the default verbs and excluded principals
+func Default() *Filter {
+	f, err := New(nil, nil, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Verbs returns the API verbs of interest
+func (f *Filter) Verbs() []string {
+	return f.verbs
+}
+
+// APIGroups returns the API groups of interest, some of which may be prefixed by a wildcard
+func (f *Filter) APIGroups() []string {
+	f.apiGroupsMu.RLock()
+	defer f.apiGroupsMu.RUnlock()
+	return slices.Clone(f.apiGroups)
+}
+
+// AddAPIGroups adds API groups of interest, e.g. those of discovered resource types. Groups already matched are
+// skipped. The groups added are returned.
+func (f *Filter) AddAPIGroups(groups ...string) []string {
+	f.apiGroupsMu.Lock()
+	defer f.apiGroupsMu.Unlock()
+
+	var added []string
+	for _, group := range groups {
+		if group == "" || matchesAPIGroup(f.apiGroups, group) {
+			continue
+		}
+		f.apiGroups = append(f.apiGroups, group)
+		added = append(added, group)
+	}
+	return added
+}
+
+// ExcludedPrincipals returns the excluded principal patterns
+func (f *Filter) ExcludedPrincipals() []string {
+	return patterns(f.excludePrincipals)
+}
+
+// IncludedPrincipals returns the included principal patterns
+func (f *Filter) IncludedPrincipals() []string {
+	return patterns(f.includePrincipals)
+}
+
+// MatchesVerb returns true if the verb is of interest
+func (f *Filter) MatchesVerb(verb string) bool {
+	return slices.Contains(f.verbs, verb)
+}
+
+// MatchesAPIGroup returns true if resources of the API group are of interest
+func (f *Filter) MatchesAPIGroup(group string) bool {
+	f.apiGroupsMu.RLock()
+	defer f.apiGroupsMu.RUnlock()
+	return matchesAPIGroup(f.apiGroups, group)
+}
+
+// matchesAPIGroup returns true if the API group is matched by any of the patterns
+func matchesAPIGroup(patterns []string, group string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(group, suffix) {
+				return true
+			}
+		} else if group == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPrincipal returns true if the principal is of interest
+func (f *Filter) MatchesPrincipal(principal string) bool {
+	for _, re := range f.excludePrincipals {
+		if re.MatchString(principal) {
+			return false
+		}
+	}
+	if len(f.includePrincipals) == 0 {
+		return true
+	}
+	for _, re := range f.includePrincipals {
+		if re.MatchString(principal) {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(exprs []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func patterns(res []*regexp.Regexp) []string {
+	out := make([]string, 0, len(res))
+	for _, re := range res {
+		out = append(out, re.String())
+	}
+	return out
+}
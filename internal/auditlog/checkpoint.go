@@ -0,0 +1,86 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+)
+
+type checkpointStore interface {
+	GetCheckpoint(name string) ([]byte, error)
+	SaveCheckpoint(name string, value []byte) error
+}
+
+// checkpoint identifies the most recent log entry that has been handled
+type checkpoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	InsertID  string    `json:"insertId"`
+}
+
+// covers returns true if the entry was logged at or before the checkpoint, and so has already been handled
+func (c checkpoint) covers(entry *loggingpb.LogEntry) bool {
+	ts := entry.GetTimestamp().AsTime()
+	if ts.Before(c.Timestamp) {
+		return true
+	}
+	return ts.Equal(c.Timestamp) && entry.GetInsertId() == c.InsertID
+}
+
+// checkpointer persists the position reached within the audit log
+type checkpointer struct {
+	store   checkpointStore
+	name    string
+	current checkpoint
+}
+
+func newCheckpointer(store checkpointStore, name string) *checkpointer {
+	return &checkpointer{
+		store: store,
+		name:  name,
+	}
+}
+
+// load fetches the persisted checkpoint. A zero value checkpoint is returned if none exists.
+func (c *checkpointer) load() (checkpoint, error) {
+	value, err := c.store.GetCheckpoint(c.name)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return checkpoint{}, nil
+		}
+		return checkpoint{}, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(value, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	c.current = cp
+	return cp, nil
+}
+
+// advance moves the checkpoint forward to the supplied entry. Entries older than the current checkpoint are ignored,
+// as entries are not guaranteed to be received in order.
+func (c *checkpointer) advance(entry *loggingpb.LogEntry) error {
+	ts := entry.GetTimestamp().AsTime()
+	if ts.Before(c.current.Timestamp) {
+		return nil
+	}
+
+	cp := checkpoint{
+		Timestamp: ts,
+		InsertID:  entry.GetInsertId(),
+	}
+	value, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err = c.store.SaveCheckpoint(c.name, value); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	c.current = cp
+	return nil
+}
@@ -0,0 +1,132 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// cloudPlatformScope is requested when impersonating, as it covers both Cloud Logging and Pub/Sub
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// ClientConfig holds optional settings for the Google Cloud clients. By default, application default credentials and
+// the production endpoints are used.
+type ClientConfig struct {
+	// CredentialsFile is the path to a service account key (or other credentials) file
+	CredentialsFile string
+	// ImpersonateServiceAccount is the email of a service account to impersonate, using the base credentials
+	ImpersonateServiceAccount string
+	// QuotaProject is the project billed for, and whose quota is consumed by, requests
+	QuotaProject string
+	// Endpoint overrides the Cloud Logging endpoint, in the form host:port
+	Endpoint string
+	// Insecure connects to the endpoint without TLS or authentication, e.g. to use a local fake server
+	Insecure bool
+}
+
+// Validate checks that the configuration is usable, so that problems are reported at startup rather than when the
+// client is first used
+func (c ClientConfig) Validate() error {
+	if c.CredentialsFile != "" {
+		data, err := os.ReadFile(c.CredentialsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		var credentials struct {
+			Type string `json:"type"`
+		}
+		if err = json.Unmarshal(data, &credentials); err != nil {
+			return fmt.Errorf("failed to parse credentials file: %w", err)
+		}
+		if credentials.Type == "" {
+			return errors.New("credentials file does not specify a type")
+		}
+	}
+	if c.ImpersonateServiceAccount != "" && !strings.Contains(c.ImpersonateServiceAccount, "@") {
+		return fmt.Errorf("invalid service account to impersonate: %s", c.ImpersonateServiceAccount)
+	}
+	if c.Endpoint != "" {
+		if _, _, err := net.SplitHostPort(c.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint: %w", err)
+		}
+	}
+	if c.Insecure {
+		if c.Endpoint == "" {
+			return errors.New("an endpoint must be supplied when insecure")
+		}
+		if c.CredentialsFile != "" || c.ImpersonateServiceAccount != "" {
+			return errors.New("credentials cannot be used when insecure")
+		}
+	}
+	return nil
+}
+
+// grpcOptions returns the options used to create the Cloud Logging gRPC client
+func (c ClientConfig) grpcOptions(ctx context.Context) ([]option.ClientOption, error) {
+	if c.Insecure {
+		return []option.ClientOption{
+			option.WithEndpoint(c.Endpoint),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		}, nil
+	}
+
+	opts, err := c.credentialOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(c.Endpoint))
+	}
+	return opts, nil
+}
+
+// restOptions returns the options used to create the Cloud Logging REST client
+func (c ClientConfig) restOptions(ctx context.Context) ([]option.ClientOption, error) {
+	if c.Insecure {
+		return []option.ClientOption{
+			option.WithEndpoint(fmt.Sprintf("http://%s/", c.Endpoint)),
+			option.WithoutAuthentication(),
+		}, nil
+	}
+
+	opts, err := c.credentialOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(fmt.Sprintf("https://%s/", c.Endpoint)))
+	}
+	return opts, nil
+}
+
+// credentialOptions returns the options relating to credentials and billing, which apply to all Google Cloud clients
+func (c ClientConfig) credentialOptions(ctx context.Context) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if c.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	if c.ImpersonateServiceAccount != "" {
+		tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: c.ImpersonateServiceAccount,
+			Scopes:          []string{cloudPlatformScope},
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account: %w", err)
+		}
+		opts = []option.ClientOption{option.WithTokenSource(tokenSource)}
+	}
+	if c.QuotaProject != "" {
+		opts = append(opts, option.WithQuotaProject(c.QuotaProject))
+	}
+	return opts, nil
+}
@@ -0,0 +1,65 @@
+package auditlog
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/metrics"
+)
+
+// seenTTL is how long handled entries are remembered for. Redelivery happens within minutes of the original delivery,
+// so this comfortably covers restarts while keeping the set of remembered entries bounded.
+const seenTTL = time.Hour * 24
+
+type seenStore interface {
+	HasSeen(key string) (bool, error)
+	MarkSeen(key string, ttl time.Duration) error
+}
+
+// store is the persistence required by the sources in this package
+type store interface {
+	checkpointStore
+	seenStore
+}
+
+// deduplicate wraps the callback, so that entries already handled (e.g. redelivered after a stream restart, or by
+// Pub/Sub) are dropped. Entries are identified by their log name and insert ID, which Cloud Logging treats as unique.
+func deduplicate(
+	cluster Cluster,
+	store seenStore,
+	cb func(*loggingpb.LogEntry, *audit.AuditLog) error,
+) func(*loggingpb.LogEntry, *audit.AuditLog) error {
+	duplicates := metrics.DuplicateAuditEntries.WithLabelValues(cluster.displayName())
+
+	return func(entry *loggingpb.LogEntry, auditLog *audit.AuditLog) error {
+		key := seenKey(entry)
+
+		seen, err := store.HasSeen(key)
+		if err != nil {
+			return fmt.Errorf("failed to check for duplicate: %w", err)
+		}
+		if seen {
+			slog.Debug("dropping duplicate log entry", slog.String("insertId", entry.GetInsertId()))
+			duplicates.Inc()
+			return nil
+		}
+
+		if err = cb(entry, auditLog); err != nil {
+			return err
+		}
+
+		if err = store.MarkSeen(key, seenTTL); err != nil {
+			return fmt.Errorf("failed to mark log entry as seen: %w", err)
+		}
+		return nil
+	}
+}
+
+// seenKey identifies the entry amongst those marked as seen
+func seenKey(entry *loggingpb.LogEntry) string {
+	return fmt.Sprintf("auditlog:%s:%s", entry.GetLogName(), entry.GetInsertId())
+}
@@ -0,0 +1,64 @@
+package auditlog
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+)
+
+// DecodeEntry decodes a log entry from its JSON representation, as returned by the Cloud Logging REST API, exported
+// by the Cloud Console, or published by a log sink
+func DecodeEntry(data []byte) (*loggingpb.LogEntry, error) {
+	var entry loggingpb.LogEntry
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// responseObject returns the resource recorded as the response of the operation, if any
+func responseObject(auditLog *audit.AuditLog) ([]byte, bool) {
+	response := auditLog.GetResponse()
+	if response == nil || response.GetFields()["spec"] == nil {
+		return nil, false
+	}
+	data, err := response.MarshalJSON()
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// requestBody returns the request recorded for the operation, if any
+func requestBody(auditLog *audit.AuditLog) ([]byte, bool) {
+	request := auditLog.GetRequest()
+	if request == nil {
+		return nil, false
+	}
+	data, err := request.MarshalJSON()
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// isCreate returns true if the operation created the resource
+func isCreate(auditLog *audit.AuditLog) bool {
+	return verb(auditLog) == auditfilter.VerbCreate
+}
+
+// isDelete returns true if the operation deleted the resource
+func isDelete(auditLog *audit.AuditLog) bool {
+	return verb(auditLog) == auditfilter.VerbDelete
+}
+
+// verb returns the API verb of the operation, which is the last segment of the method name
+func verb(auditLog *audit.AuditLog) string {
+	method := auditLog.GetMethodName()
+	return method[strings.LastIndex(method, ".")+1:]
+}
@@ -0,0 +1,168 @@
+package auditlog
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+)
+
+// Cluster identifies the GKE cluster whose audit logs are read
+type Cluster struct {
+	ProjectID string
+	Name      string
+	// DisplayName identifies the cluster in metrics, as it does elsewhere. If empty, the name is used.
+	DisplayName string
+	// LogBucket optionally names the log bucket view entries are read from, in the form
+	// projects/PROJECT/locations/LOCATION/buckets/BUCKET/views/VIEW. By default, the project is used.
+	LogBucket string
+	// Filter determines the verbs and principals of interest. If nil, the default filter is used.
+	Filter *auditfilter.Filter
+	// Client holds optional settings for the Google Cloud clients used to obtain entries
+	Client ClientConfig
+}
+
+// resourceNames returns the parent resources that entries are read from
+func (c Cluster) resourceNames() []string {
+	if c.LogBucket != "" {
+		return []string{c.LogBucket}
+	}
+	return []string{fmt.Sprintf("projects/%s", c.ProjectID)}
+}
+
+// displayName returns the name identifying the cluster in metrics
+func (c Cluster) displayName() string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return c.Name
+}
+
+// checkpointName returns the name under which the position reached in the audit log is checkpointed
+func (c Cluster) checkpointName() string {
+	return fmt.Sprintf("auditlog:%s:%s", c.ProjectID, c.Name)
+}
+
+// filter returns the Cloud Logging filter that matches audit log entries of interest
+func (c Cluster) filter() string {
+	f := c.auditFilter()
+
+	clauses := []string{
+		`resource.type="k8s_cluster"`,
+		fmt.Sprintf(`log_name="%s"`, c.logName()),
+		fmt.Sprintf(`resource.labels.cluster_name="%s"`, c.Name),
+		`protoPayload."@type"="type.googleapis.com/google.cloud.audit.AuditLog"`,
+		fmt.Sprintf(`protoPayload.methodName=~"%s"`, quote(c.methodPattern())),
+	}
+	for _, pattern := range f.ExcludedPrincipals() {
+		clauses = append(clauses, fmt.Sprintf(`-protoPayload.authenticationInfo.principalEmail=~"%s"`, quote(pattern)))
+	}
+	if included := f.IncludedPrincipals(); len(included) > 0 {
+		alternatives := make([]string, 0, len(included))
+		for _, pattern := range included {
+			alternatives = append(alternatives, fmt.Sprintf(`protoPayload.authenticationInfo.principalEmail=~"%s"`, quote(pattern)))
+		}
+		clauses = append(clauses, fmt.Sprintf("(%s)", strings.Join(alternatives, " OR ")))
+	}
+
+	return strings.Join(clauses, " AND ")
+}
+
+// matches evaluates the filter against an entry client-side. This is required where entries are not obtained via a
+// Cloud Logging query, such as when they are delivered via a log sink. If the project or cluster name are not set,
+// entries from any project or cluster are matched.
+func (c Cluster) matches(entry *loggingpb.LogEntry, auditLog *audit.AuditLog) bool {
+	if entry.GetResource().GetType() != "k8s_cluster" {
+		return false
+	}
+	if c.ProjectID != "" && entry.GetLogName() != c.logName() {
+		return false
+	}
+	if !strings.HasSuffix(entry.GetLogName(), "/logs/cloudaudit.googleapis.com%2Factivity") {
+		return false
+	}
+	if c.Name != "" && entry.GetResource().GetLabels()["cluster_name"] != c.Name {
+		return false
+	}
+	method := auditLog.GetMethodName()
+	if !slices.ContainsFunc(methodAPIGroups(method), c.auditFilter().MatchesAPIGroup) {
+		return false
+	}
+	if !c.auditFilter().MatchesVerb(method[strings.LastIndex(method, ".")+1:]) {
+		return false
+	}
+	return c.auditFilter().MatchesPrincipal(auditLog.GetAuthenticationInfo().GetPrincipalEmail())
+}
+
+func (c Cluster) auditFilter() *auditfilter.Filter {
+	if c.Filter == nil {
+		return auditfilter.Default()
+	}
+	return c.Filter
+}
+
+// methodPattern returns a regular expression matching the method names of operations of interest. Method names take
+// the form <reversed api group>.<version>.<resource>.<verb>, e.g. io.fluxcd.toolkit.kustomize.v1.kustomizations.patch.
+// The built-in API groups without a domain are prefixed by io.k8s, e.g. io.k8s.apps.v1.deployments.patch.
+func (c Cluster) methodPattern() string {
+	verbs := c.auditFilter().Verbs()
+	quotedVerbs := make([]string, 0, len(verbs))
+	for _, verb := range verbs {
+		quotedVerbs = append(quotedVerbs, regexp.QuoteMeta(verb))
+	}
+	groups := c.auditFilter().APIGroups()
+	quotedGroups := make([]string, 0, len(groups))
+	for _, group := range groups {
+		quotedGroups = append(quotedGroups, reversedGroupPattern(group))
+	}
+	return fmt.Sprintf(`^(%s)\.[^.]+\.[^.]+\.(%s)$`, strings.Join(quotedGroups, "|"), strings.Join(quotedVerbs, "|"))
+}
+
+// reversedGroupPattern returns a regular expression matching the API group as it appears in method names, with its
+// segments reversed. A leading wildcard matches one or more trailing segments.
+func reversedGroupPattern(group string) string {
+	if !strings.Contains(group, ".") {
+		return `io\.k8s\.` + regexp.QuoteMeta(group)
+	}
+	suffix, wildcard := strings.CutPrefix(group, "*.")
+	segments := strings.Split(suffix, ".")
+	slices.Reverse(segments)
+	for i, segment := range segments {
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	pattern := strings.Join(segments, `\.`)
+	if wildcard {
+		pattern += `\..+`
+	}
+	return pattern
+}
+
+// methodAPIGroups returns the API group of the resource an operation was made against, derived from the method name.
+// Method names starting io.k8s may relate to either a built-in API group without a domain (e.g. apps), or one under
+// k8s.io (e.g. networking.k8s.io), so both are returned.
+func methodAPIGroups(method string) []string {
+	segments := strings.Split(method, ".")
+	if len(segments) < 4 {
+		return nil
+	}
+	segments = segments[:len(segments)-3]
+	if len(segments) == 3 && segments[0] == "io" && segments[1] == "k8s" {
+		return []string{segments[2], segments[2] + ".k8s.io"}
+	}
+	slices.Reverse(segments)
+	return []string{strings.Join(segments, ".")}
+}
+
+func (c Cluster) logName() string {
+	return fmt.Sprintf("projects/%s/logs/cloudaudit.googleapis.com%%2Factivity", c.ProjectID)
+}
+
+// quote escapes a value for use within a quoted Cloud Logging filter string
+func quote(s string) string {
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
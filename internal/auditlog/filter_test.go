@@ -0,0 +1,98 @@
+package auditlog
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+)
+
+func TestCluster_methodPattern(t *testing.T) {
+	filter, err := auditfilter.New(
+		[]string{"patch", "delete"},
+		nil,
+		nil,
+		[]string{"infra.contrib.fluxcd.io", "*.acme.io", "apps"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(Cluster{Filter: filter}.methodPattern())
+
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{method: "io.fluxcd.toolkit.kustomize.v1.kustomizations.patch", want: true},
+		{method: "io.fluxcd.toolkit.helm.v2.helmreleases.delete", want: true},
+		{method: "io.fluxcd.toolkit.kustomize.v1.kustomizations.create"},
+		{method: "io.fluxcd.toolkit.v1.kustomizations.patch"},
+		{method: "io.fluxcd.contrib.infra.v1alpha2.terraforms.patch", want: true},
+		{method: "io.acme.ops.v1.rollouts.patch", want: true},
+		{method: "io.acme.v1.rollouts.patch"},
+		{method: "io.acmexio.ops.v1.rollouts.patch"},
+		{method: "io.k8s.apps.v1.deployments.patch", want: true},
+		{method: "io.k8s.batch.v1.cronjobs.patch"},
+		{method: "io.fluxcd.toolkit.kustomize.v1.kustomizations.patch.extra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if got := pattern.MatchString(tt.method); got != tt.want {
+				t.Errorf("methodPattern() matches %q = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCluster_filter(t *testing.T) {
+	filter, err := auditfilter.New(
+		nil,
+		[]string{`^system:serviceaccount:flux-system:.*-controller$`},
+		[]string{`@acme\.com$`, `^ci-"bot"$`},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cluster Cluster
+		want    []string
+	}{
+		{
+			name:    "default filter",
+			cluster: Cluster{ProjectID: "acme-production", Name: "main"},
+			want: []string{
+				`resource.type="k8s_cluster"`,
+				`log_name="projects/acme-production/logs/cloudaudit.googleapis.com%2Factivity"`,
+				`resource.labels.cluster_name="main"`,
+				`protoPayload."@type"="type.googleapis.com/google.cloud.audit.AuditLog"`,
+				`protoPayload.methodName=~"^(io\.fluxcd\.toolkit\..+)\.[^.]+\.[^.]+\.(patch|create|delete)$"`,
+				`-protoPayload.authenticationInfo.principalEmail=~"^system:serviceaccount:flux-system:.*-controller$"`,
+			},
+		},
+		{
+			name:    "included principals",
+			cluster: Cluster{ProjectID: "acme-production", Name: "main", Filter: filter},
+			want: []string{
+				`resource.type="k8s_cluster"`,
+				`log_name="projects/acme-production/logs/cloudaudit.googleapis.com%2Factivity"`,
+				`resource.labels.cluster_name="main"`,
+				`protoPayload."@type"="type.googleapis.com/google.cloud.audit.AuditLog"`,
+				`protoPayload.methodName=~"^(io\.fluxcd\.toolkit\..+)\.[^.]+\.[^.]+\.(patch|create|delete)$"`,
+				`-protoPayload.authenticationInfo.principalEmail=~"^system:serviceaccount:flux-system:.*-controller$"`,
+				`(protoPayload.authenticationInfo.principalEmail=~"@acme\.com$" OR protoPayload.authenticationInfo.principalEmail=~"^ci-\"bot\"$")`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := strings.Join(tt.want, " AND ")
+			if got := tt.cluster.filter(); got != want {
+				t.Errorf("filter() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+)
+
+const (
+	defaultPollInterval = time.Second * 30
+	defaultPageSize     = 100
+	// pollLookback is how far before the cursor each poll, and each backfill by Tail, starts. Cloud Logging ingests
+	// entries late, so an entry may become visible after a newer one has already been handled; listing overlaps to pick
+	// it up. Entries already handled are dropped, so this must not exceed seenTTL.
+	pollLookback = time.Minute * 10
+)
+
+// Poll periodically lists audit log entries relating to fluxcd resources via the Cloud Logging REST API (entries.list).
+// The same entries are matched as by Tail, and the same checkpoint is used as the cursor, so switching between the
+// two is seamless. If no checkpoint exists, polling starts from the current time. Each poll starts a little before the
+// cursor, to pick up entries ingested late; as with Tail, entries already handled are dropped.
+func Poll(
+	ctx context.Context,
+	cluster Cluster,
+	store store,
+	interval time.Duration,
+	pageSize int64,
+	cb func(*loggingpb.LogEntry, *audit.AuditLog) error,
+) error {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	opts, err := cluster.Client.restOptions(ctx)
+	if err != nil {
+		return err
+	}
+	service, err := logging.NewService(ctx, append(opts, option.WithScopes(logging.LoggingReadScope))...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+
+	p := &poller{
+		service:     service,
+		cluster:     cluster,
+		store:       store,
+		checkpoints: newCheckpointer(store, cluster.checkpointName()),
+		pageSize:    pageSize,
+		cb:          deduplicate(cluster, store, cb),
+	}
+
+	cp, err := p.checkpoints.load()
+	if err != nil {
+		return err
+	}
+	if cp.Timestamp.IsZero() {
+		p.checkpoints.current.Timestamp = time.Now().UTC()
+		p.notBefore = p.checkpoints.current.Timestamp
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err = p.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type poller struct {
+	service     *logging.Service
+	cluster     Cluster
+	store       seenStore
+	checkpoints *checkpointer
+	// notBefore is when polling started from, if there was no checkpoint, which the lookback does not reach beyond
+	notBefore time.Time
+	pageSize  int64
+	cb        func(*loggingpb.LogEntry, *audit.AuditLog) error
+}
+
+// poll lists and handles all entries logged since shortly before the cursor. Failures to list entries are logged,
+// rather than returned, as they will be retried by the next poll.
+func (p *poller) poll(ctx context.Context) error {
+	cp := p.checkpoints.current
+	from := cp.Timestamp.Add(-pollLookback)
+	if from.Before(p.notBefore) {
+		from = p.notBefore
+	}
+
+	req := &logging.ListLogEntriesRequest{
+		ResourceNames: p.cluster.resourceNames(),
+		Filter: fmt.Sprintf(
+			`%s AND timestamp>="%s"`,
+			p.cluster.filter(),
+			from.Format(time.RFC3339Nano),
+		),
+		OrderBy:  "timestamp asc",
+		PageSize: p.pageSize,
+	}
+
+	var (
+		count int
+		cbErr error
+	)
+	err := p.service.Entries.List(req).Pages(ctx, func(resp *logging.ListLogEntriesResponse) error {
+		for _, restEntry := range resp.Entries {
+			raw, err := json.Marshal(restEntry)
+			if err != nil {
+				return fmt.Errorf("failed to marshal log entry: %w", err)
+			}
+			entry, err := DecodeEntry(raw)
+			if err != nil {
+				slog.Warn("failed to decode log entry", slog.Any("error", err), slog.String("insertId", restEntry.InsertId))
+				continue
+			}
+			if cp.covers(entry) {
+				// Within the overlap, so only handled if it was ingested late. Those handled by a previous poll are
+				// skipped here, rather than counted as duplicates.
+				seen, err := p.store.HasSeen(seenKey(entry))
+				if err != nil {
+					cbErr = fmt.Errorf("failed to check for duplicate: %w", err)
+					return cbErr
+				}
+				if seen {
+					continue
+				}
+			}
+
+			auditLog, ok := auditLogFromEntry(entry)
+			if !ok {
+				continue
+			}
+			if err = p.cb(entry, auditLog); err != nil {
+				cbErr = fmt.Errorf("callback failed: %w", err)
+				return cbErr
+			}
+			if err = p.checkpoints.advance(entry); err != nil {
+				cbErr = err
+				return cbErr
+			}
+			count++
+		}
+		return nil
+	})
+	if cbErr != nil {
+		return cbErr
+	}
+	if err != nil {
+		slog.Warn("list log entries failed, will retry", slog.Any("error", err))
+		return nil
+	}
+
+	slog.Debug("polled audit log entries", slog.Int("entries", count))
+	return nil
+}
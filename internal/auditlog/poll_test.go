@@ -0,0 +1,199 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+)
+
+func TestPoller_pollPicksUpLateEntries(t *testing.T) {
+	t0 := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	server := &fakeLoggingServer{}
+	server.ingest("a", t0.Add(time.Minute))
+
+	p, handled := newTestPoller(t, server, newFakeStore())
+	p.checkpoints.current.Timestamp = t0
+	p.notBefore = t0
+
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertHandled(t, *handled, "a")
+
+	// Logged just before the checkpoint, but only ingested after the previous poll
+	server.ingest("b", t0.Add(time.Second*30))
+	server.ingest("c", t0.Add(time.Minute*2))
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertHandled(t, *handled, "a", "b", "c")
+
+	if got := p.checkpoints.current; !got.Timestamp.Equal(t0.Add(time.Minute*2)) || got.InsertID != "c" {
+		t.Errorf("unexpected checkpoint: %+v", got)
+	}
+}
+
+func TestPoller_pollDoesNotLookBackBeforeStart(t *testing.T) {
+	t0 := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	server := &fakeLoggingServer{}
+	server.ingest("a", t0.Add(-time.Minute))
+	server.ingest("b", t0.Add(time.Minute))
+
+	p, handled := newTestPoller(t, server, newFakeStore())
+	p.checkpoints.current.Timestamp = t0
+	p.notBefore = t0
+
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertHandled(t, *handled, "b")
+}
+
+func newTestPoller(t *testing.T, server *fakeLoggingServer, store *fakeStore) (*poller, *[]string) {
+	t.Helper()
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	service, err := logging.NewService(
+		context.Background(),
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := Cluster{ProjectID: "acme-production", Name: "main"}
+	var handled []string
+	return &poller{
+		service:     service,
+		cluster:     cluster,
+		store:       store,
+		checkpoints: newCheckpointer(store, cluster.checkpointName()),
+		pageSize:    defaultPageSize,
+		cb: deduplicate(cluster, store, func(entry *loggingpb.LogEntry, _ *audit.AuditLog) error {
+			handled = append(handled, entry.GetInsertId())
+			return nil
+		}),
+	}, &handled
+}
+
+func assertHandled(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("handled %v, want %v", got, want)
+	}
+}
+
+var timestampFilter = regexp.MustCompile(`timestamp>="([^"]+)"`)
+
+// fakeLoggingServer serves entries.list, returning the entries ingested so far that match the timestamp filter
+type fakeLoggingServer struct {
+	mu      sync.Mutex
+	entries []map[string]any
+}
+
+func (s *fakeLoggingServer) ingest(insertID string, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, map[string]any{
+		"insertId":  insertID,
+		"logName":   "projects/acme-production/logs/cloudaudit.googleapis.com%2Factivity",
+		"timestamp": timestamp.Format(time.RFC3339Nano),
+		"protoPayload": map[string]any{
+			"@type":        "type.googleapis.com/google.cloud.audit.AuditLog",
+			"methodName":   "io.fluxcd.toolkit.kustomize.v1.kustomizations.patch",
+			"resourceName": "kustomize.toolkit.fluxcd.io/v1/namespaces/flux-system/kustomizations/podinfo",
+		},
+	})
+}
+
+func (s *fakeLoggingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req logging.ListLogEntriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	match := timestampFilter.FindStringSubmatch(req.Filter)
+	if match == nil {
+		http.Error(w, "missing timestamp filter", http.StatusBadRequest)
+		return
+	}
+	from, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []map[string]any
+	for _, entry := range s.entries {
+		ts, _ := time.Parse(time.RFC3339Nano, entry["timestamp"].(string))
+		if !ts.Before(from) {
+			entries = append(entries, entry)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"entries": entries})
+}
+
+// fakeStore is an in-memory store
+type fakeStore struct {
+	mu          sync.Mutex
+	checkpoints map[string][]byte
+	seen        map[string]struct{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		checkpoints: make(map[string][]byte),
+		seen:        make(map[string]struct{}),
+	}
+}
+
+func (s *fakeStore) GetCheckpoint(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.checkpoints[name]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fakeStore) SaveCheckpoint(name string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[name] = value
+	return nil
+}
+
+func (s *fakeStore) HasSeen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.seen[key]
+	return ok, nil
+}
+
+func (s *fakeStore) MarkSeen(key string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[key] = struct{}{}
+	return nil
+}
@@ -0,0 +1,91 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"cloud.google.com/go/pubsub"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// PubSubSource is a source.Source implementation backed by GKE audit logs, routed to a Pub/Sub topic via a Log Router
+// sink. Messages are acknowledged only once they have been successfully handled, and entries already handled are
+// dropped, so that redelivered messages are only handled once.
+//
+// The PUBSUB_EMULATOR_HOST environment variable is honoured, allowing the source to be used against the emulator.
+type PubSubSource struct {
+	cluster               Cluster
+	store                 seenStore
+	subscriptionProjectID string
+	subscriptionID        string
+}
+
+// NewPubSubSource instantiates and returns PubSubSource. The cluster is used to filter entries, as the sink may route
+// entries for other clusters to the same topic. Handled entries are remembered in the supplied store.
+func NewPubSubSource(cluster Cluster, store seenStore, subscriptionProjectID, subscriptionID string) *PubSubSource {
+	if subscriptionProjectID == "" {
+		subscriptionProjectID = cluster.ProjectID
+	}
+	return &PubSubSource{
+		cluster:               cluster,
+		store:                 store,
+		subscriptionProjectID: subscriptionProjectID,
+		subscriptionID:        subscriptionID,
+	}
+}
+
+// Run pulls messages from the subscription, passing successful operations to the handler
+func (s *PubSubSource) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	opts, err := s.cluster.Client.credentialOptions(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := pubsub.NewClient(ctx, s.subscriptionProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	sub := client.Subscription(s.subscriptionID)
+	// Messages are handled one at a time, as the handler is not safe for concurrent use
+	sub.ReceiveSettings.NumGoroutines = 1
+	sub.ReceiveSettings.MaxOutstandingMessages = 1
+
+	cb := deduplicate(s.cluster, s.store, entryHandler(ctx, handle))
+
+	slog.Info("receiving audit log entries", slog.String("subscription", sub.String()))
+
+	err = sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		entry, err := DecodeEntry(msg.Data)
+		if err != nil {
+			// Redelivery won't help, so the message is dropped
+			slog.Warn("failed to decode log entry", slog.Any("error", err), slog.String("messageId", msg.ID))
+			msg.Ack()
+			return
+		}
+
+		auditLog, ok := auditLogFromEntry(entry)
+		if !ok || !s.cluster.matches(entry, auditLog) {
+			msg.Ack()
+			return
+		}
+
+		if err = cb(entry, auditLog); err != nil {
+			slog.Error(
+				"failed to handle log entry, will be redelivered",
+				slog.Any("error", err),
+				slog.String("insertId", entry.GetInsertId()),
+			)
+			msg.Nack()
+			return
+		}
+		msg.Ack()
+	})
+	if err != nil {
+		return fmt.Errorf("receive failed: %w", err)
+	}
+	return ctx.Err()
+}
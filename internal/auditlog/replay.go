@@ -0,0 +1,136 @@
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// ReplaySource is a source.Source implementation that replays audit log entries exported from Cloud Logging, either
+// as a JSON array (as produced by `gcloud logging read --format=json`) or as JSON lines. Entries are filtered
+// client-side in the same way as entries delivered via a log sink, and are replayed in timestamp order.
+//
+// As the cluster cannot be consulted for historical state, only deletions and entries that record their request are
+// passed on, so that those whose request sets the suspension status can be replayed.
+type ReplaySource struct {
+	cluster Cluster
+	paths   []string
+}
+
+// NewReplaySource instantiates and returns ReplaySource. The project and cluster name may be left empty, to replay
+// entries relating to any cluster.
+func NewReplaySource(cluster Cluster, paths []string) *ReplaySource {
+	return &ReplaySource{
+		cluster: cluster,
+		paths:   paths,
+	}
+}
+
+// Run reads all exported entries, and passes those relating to suspension changes to the handler
+func (s *ReplaySource) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	var entries []*loggingpb.LogEntry
+	for _, path := range s.paths {
+		fileEntries, err := readExportFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	// Exports are typically ordered newest first
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].GetTimestamp().AsTime().Before(entries[j].GetTimestamp().AsTime())
+	})
+
+	slog.Info("replaying audit log entries", slog.Int("entries", len(entries)))
+
+	for _, entry := range entries {
+		auditLog, ok := auditLogFromEntry(entry)
+		if !ok || !s.cluster.matches(entry, auditLog) {
+			continue
+		}
+
+		event, ok, err := newEvent(entry, auditLog)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if !event.Deleted && event.Request == nil {
+			slog.Debug("entry does not record the request, skipping", slog.String("insertId", entry.GetInsertId()))
+			continue
+		}
+
+		if err = handle(ctx, event); err != nil {
+			return fmt.Errorf("handler failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// readExportFile reads log entries from an exported JSON or JSON lines file
+func readExportFile(path string) ([]*loggingpb.LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	dec := json.NewDecoder(r)
+
+	// Entries are either wrapped in an array, or follow one another
+	first, err := peekNonSpace(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read export file: %w", err)
+	}
+	if first == '[' {
+		if _, err = dec.Token(); err != nil {
+			return nil, fmt.Errorf("failed to read export file %s: %w", path, err)
+		}
+	}
+
+	var entries []*loggingpb.LogEntry
+	for dec.More() {
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to read export file %s: %w", path, err)
+		}
+		entry, err := DecodeEntry(raw)
+		if err != nil {
+			slog.Warn("failed to decode log entry", slog.Any("error", err), slog.String("path", path))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return 0, err
+		}
+		switch c := b[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c, nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package auditlog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/cloud/audit"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// Source is a source.Source implementation backed by GKE audit logs, obtained from Cloud Logging
+type Source struct {
+	cluster Cluster
+	store   store
+}
+
+// NewSource instantiates and returns Source. The position reached within the audit log, and the entries recently
+// handled, are persisted to the supplied store.
+func NewSource(cluster Cluster, store store) *Source {
+	return &Source{
+		cluster: cluster,
+		store:   store,
+	}
+}
+
+// Run tails audit logs, passing successful operations to the handler
+func (s *Source) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	return Tail(ctx, s.cluster, s.store, entryHandler(ctx, handle))
+}
+
+// PollingSource is a source.Source implementation backed by GKE audit logs, obtained by periodically polling Cloud
+// Logging over REST. This is intended for environments where long-lived gRPC streams cannot be maintained.
+type PollingSource struct {
+	cluster  Cluster
+	store    store
+	interval time.Duration
+	pageSize int64
+}
+
+// NewPollingSource instantiates and returns PollingSource. The timestamp cursor, and the entries recently handled, are
+// persisted to the supplied store.
+func NewPollingSource(cluster Cluster, store store, interval time.Duration, pageSize int64) *PollingSource {
+	return &PollingSource{
+		cluster:  cluster,
+		store:    store,
+		interval: interval,
+		pageSize: pageSize,
+	}
+}
+
+// Run polls audit logs, passing successful operations to the handler
+func (s *PollingSource) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	return Poll(ctx, s.cluster, s.store, s.interval, s.pageSize, entryHandler(ctx, handle))
+}
+
+// entryHandler returns a callback that converts audit log entries to events, and passes them to the handler
+func entryHandler(ctx context.Context, handle source.Handler) func(*loggingpb.LogEntry, *audit.AuditLog) error {
+	return func(entry *loggingpb.LogEntry, auditLog *audit.AuditLog) error {
+		event, ok, err := newEvent(entry, auditLog)
+		if err != nil {
+			// The entry will never convert, so it is skipped rather than stopping the source
+			slog.Error(
+				"failed to convert log entry, skipping",
+				slog.Any("error", err),
+				slog.String("insertId", entry.GetInsertId()),
+				slog.String("resourceName", auditLog.GetResourceName()),
+			)
+			return nil
+		}
+		if !ok {
+			return nil
+		}
+		return handle(ctx, event)
+	}
+}
+
+// newEvent converts an audit log entry to an event. False is returned if the operation failed, in which case the
+// entry should be ignored. Where the audit log records the resulting resource, or the request, these are carried by
+// the event so that the outcome of this specific operation can be evaluated.
+func newEvent(entry *loggingpb.LogEntry, auditLog *audit.AuditLog) (source.Event, bool, error) {
+	if code := auditLog.GetStatus().GetCode(); code != 0 {
+		slog.Warn("operation appeared to fail", slog.Int("code", int(code)))
+		return source.Event{}, false, nil
+	}
+
+	resourceRef, err := k8s.ResourceReferenceFromPath(auditLog.GetResourceName())
+	if err != nil {
+		return source.Event{}, false, err
+	}
+
+	event := source.Event{
+		Resource: resourceRef,
+		Actor:    actorFromAuditLog(auditLog),
+		Time:     entry.GetTimestamp().AsTime(),
+		Created:  isCreate(auditLog),
+	}
+	if isDelete(auditLog) {
+		event.Deleted = true
+		return event, true, nil
+	}
+	if object, ok := responseObject(auditLog); ok {
+		event.Object = object
+	}
+	if request, ok := requestBody(auditLog); ok {
+		event.Request = request
+	}
+	return event, true, nil
+}
+
+// actorFromAuditLog extracts the identity that made the request, along with any identities that delegated to it, and
+// the request metadata. GKE records IAM service account delegation, which is reported via the delegates, but does not
+// record the user impersonated via kubernetes impersonation (kubectl --as), so the impersonated user is never set;
+// such changes are attributed to the impersonating principal.
+func actorFromAuditLog(auditLog *audit.AuditLog) actor.Actor {
+	authInfo := auditLog.GetAuthenticationInfo()
+	requestMetadata := auditLog.GetRequestMetadata()
+
+	a := actor.Actor{
+		Principal:         authInfo.GetPrincipalEmail(),
+		AuthoritySelector: authInfo.GetAuthoritySelector(),
+		CallerIP:          requestMetadata.GetCallerIp(),
+		UserAgent:         requestMetadata.GetCallerSuppliedUserAgent(),
+	}
+	if a.Principal == "" {
+		a.Principal = authInfo.GetPrincipalSubject()
+	}
+
+	for _, delegation := range authInfo.GetServiceAccountDelegationInfo() {
+		if delegate := delegateName(delegation); delegate != "" {
+			a.Delegates = append(a.Delegates, delegate)
+		}
+	}
+	return a
+}
+
+// delegateName returns a name identifying a delegating authority. Third party principals (e.g. workload identity
+// federation) are identified by their subject claim.
+func delegateName(delegation *audit.ServiceAccountDelegationInfo) string {
+	if email := delegation.GetFirstPartyPrincipal().GetPrincipalEmail(); email != "" {
+		return email
+	}
+	if sub := delegation.GetThirdPartyPrincipal().GetThirdPartyClaims().GetFields()["sub"].GetStringValue(); sub != "" {
+		return sub
+	}
+	return delegation.GetPrincipalSubject()
+}
@@ -6,25 +6,47 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"strings"
 	"time"
 
 	logging "cloud.google.com/go/logging/apiv2"
 	"cloud.google.com/go/logging/apiv2/loggingpb"
 	"golang.org/x/time/rate"
+	"google.golang.org/api/iterator"
 	"google.golang.org/genproto/googleapis/cloud/audit"
 	"google.golang.org/grpc/status"
 )
 
 // Tail streams audit log entries relating to fluxcd resources. Only audit log entries relating to non-system users
-// patching or creating resources are returned.
-func Tail(ctx context.Context, projectID string, clusterName string, cb func(*audit.AuditLog) error) error {
-	client, err := logging.NewClient(ctx)
+// patching or creating resources are returned. The callback receives both the log entry, and its audit log payload.
+//
+// The position of the last entry successfully handled by the callback is checkpointed in the supplied store. Whenever
+// tailing (re)starts, entries logged since the checkpoint are backfilled before switching to the live tail, so that
+// entries emitted while the stream was down or the application was not running are not lost. Handled entries are also
+// remembered in the store, so that entries redelivered after a restart are only handled once.
+func Tail(
+	ctx context.Context,
+	cluster Cluster,
+	store store,
+	cb func(*loggingpb.LogEntry, *audit.AuditLog) error,
+) error {
+	opts, err := cluster.Client.grpcOptions(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := logging.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
 	defer client.Close()
 
+	t := &tailer{
+		client:      client,
+		cluster:     cluster,
+		store:       store,
+		checkpoints: newCheckpointer(store, cluster.checkpointName()),
+		cb:          deduplicate(cluster, store, cb),
+	}
+
 	// Limiter used to throttle log tailing restarts
 	limiter := rate.NewLimiter(rate.Every(time.Second*15), 3)
 
@@ -39,7 +61,7 @@ func Tail(ctx context.Context, projectID string, clusterName string, cb func(*au
 			return fmt.Errorf("limit wait failed: %w", err)
 		}
 
-		if err = tailLogs(ctx, client, projectID, clusterName, cb); err != nil {
+		if err = t.tailLogs(ctx); err != nil {
 			if _, ok := status.FromError(err); ok {
 				slog.Warn("gRPC request terminated, restarting", slog.Any("error", err))
 				continue
@@ -49,37 +71,108 @@ func Tail(ctx context.Context, projectID string, clusterName string, cb func(*au
 	}
 }
 
-func tailLogs(ctx context.Context, client *logging.Client, projectID, clusterName string, cb func(*audit.AuditLog) error) error {
-	stream, err := client.TailLogEntries(ctx)
+type tailer struct {
+	client      *logging.Client
+	cluster     Cluster
+	store       seenStore
+	checkpoints *checkpointer
+	cb          func(*loggingpb.LogEntry, *audit.AuditLog) error
+}
+
+// tailLogs opens the tail stream, backfills any entries logged since the last checkpoint, and then reads the stream.
+// The stream is opened before backfilling so that there is no gap between the two; entries seen during the backfill
+// are skipped when subsequently received via the stream.
+func (t *tailer) tailLogs(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := t.client.TailLogEntries(ctx)
 	if err != nil {
 		return fmt.Errorf("request to tail log entries failed: %w", err)
 	}
 	defer stream.CloseSend()
 
 	req := &loggingpb.TailLogEntriesRequest{
-		ResourceNames: []string{
-			fmt.Sprintf("projects/%s", projectID),
-		},
-		Filter: strings.Join(
-			[]string{
-				`resource.type="k8s_cluster"`,
-				fmt.Sprintf(`log_name="projects/%s/logs/cloudaudit.googleapis.com%%2Factivity"`, projectID),
-				fmt.Sprintf(`resource.labels.cluster_name="%s"`, clusterName),
-				`protoPayload."@type"="type.googleapis.com/google.cloud.audit.AuditLog"`,
-				`protoPayload.methodName=~"io\.fluxcd\.toolkit\..*\.(patch|create)"`,
-				`-protoPayload.authenticationInfo.principalEmail=~"^system:serviceaccount:flux-system:.*-controller$"`,
-			},
-			" AND ",
-		),
+		ResourceNames: t.cluster.resourceNames(),
+		Filter:        t.cluster.filter(),
 	}
 	if err = stream.Send(req); err != nil {
 		return fmt.Errorf("stream send failed: %w", err)
 	}
 
-	return readStream(ctx, stream, cb)
+	backfilled, err := t.backfill(ctx)
+	if err != nil {
+		return err
+	}
+
+	return t.readStream(ctx, stream, backfilled)
 }
 
-func readStream(ctx context.Context, stream loggingpb.LoggingServiceV2_TailLogEntriesClient, cb func(*audit.AuditLog) error) error {
+// backfill lists entries logged since the last checkpoint, if there is one, and passes them to the callback. As with
+// Poll, the listing starts a little before the checkpoint, to pick up entries ingested late. The insert IDs of the
+// entries seen are returned.
+func (t *tailer) backfill(ctx context.Context) (map[string]struct{}, error) {
+	seen := make(map[string]struct{})
+
+	cp, err := t.checkpoints.load()
+	if err != nil {
+		return nil, err
+	}
+	if cp.Timestamp.IsZero() {
+		return seen, nil // Nothing processed yet, so nothing to catch up on
+	}
+
+	slog.Info("backfilling audit log entries", slog.Time("since", cp.Timestamp))
+
+	it := t.client.ListLogEntries(ctx, &loggingpb.ListLogEntriesRequest{
+		ResourceNames: t.cluster.resourceNames(),
+		Filter: fmt.Sprintf(
+			`%s AND timestamp>="%s"`,
+			t.cluster.filter(),
+			cp.Timestamp.Add(-pollLookback).Format(time.RFC3339Nano),
+		),
+		OrderBy:  "timestamp asc",
+		PageSize: 1000,
+	})
+
+	var count int
+	for {
+		entry, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list log entries failed: %w", err)
+		}
+
+		seen[entry.GetInsertId()] = struct{}{}
+		if cp.covers(entry) {
+			// Within the overlap, so only handled if it was ingested late
+			handled, err := t.store.HasSeen(seenKey(entry))
+			if err != nil {
+				return nil, fmt.Errorf("failed to check for duplicate: %w", err)
+			}
+			if handled {
+				continue
+			}
+		}
+		if err = t.handleEntry(entry); err != nil {
+			return nil, err
+		}
+		count++
+	}
+
+	slog.Info("backfill complete", slog.Int("entries", count))
+	return seen, nil
+}
+
+// readStream passes entries received via the stream to the callback, skipping those already handled. Nil is returned
+// if the server closes the stream, so that tailing is restarted.
+func (t *tailer) readStream(
+	ctx context.Context,
+	stream loggingpb.LoggingServiceV2_TailLogEntriesClient,
+	skip map[string]struct{},
+) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -88,35 +181,58 @@ func readStream(ctx context.Context, stream loggingpb.LoggingServiceV2_TailLogEn
 			resp, err := stream.Recv()
 			switch {
 			case errors.Is(err, io.EOF):
-				break
+				slog.Warn("log tailing stream closed by server, restarting")
+				return nil
 			case err != nil:
 				return fmt.Errorf("stream receive failed: %w", err)
 			default:
 			}
 
 			for _, entry := range resp.GetEntries() {
-				payload := entry.GetProtoPayload()
-				if payload == nil {
-					slog.Warn("unexpected payload type")
-					continue
+				if _, ok := skip[entry.GetInsertId()]; ok {
+					continue // Already handled during the backfill
 				}
-
-				msg, err := payload.UnmarshalNew()
-				if err != nil {
-					slog.Warn("failed to unmarshal payload", slog.Any("error", err))
-					continue
-				}
-
-				auditLog, ok := msg.(*audit.AuditLog)
-				if !ok {
-					slog.Warn("unexpected payload type", slog.Any("type", fmt.Sprintf("%t", msg)))
-					continue
-				}
-
-				if err = cb(auditLog); err != nil {
-					return fmt.Errorf("callback failed: %w", err)
+				if err = t.handleEntry(entry); err != nil {
+					return err
 				}
 			}
 		}
 	}
 }
+
+// handleEntry passes the entry to the callback, and checkpoints it if successful
+func (t *tailer) handleEntry(entry *loggingpb.LogEntry) error {
+	auditLog, ok := auditLogFromEntry(entry)
+	if !ok {
+		return nil
+	}
+
+	if err := t.cb(entry, auditLog); err != nil {
+		return fmt.Errorf("callback failed: %w", err)
+	}
+
+	return t.checkpoints.advance(entry)
+}
+
+// auditLogFromEntry extracts the audit log payload from a log entry. False is returned if the entry does not carry
+// an audit log payload.
+func auditLogFromEntry(entry *loggingpb.LogEntry) (*audit.AuditLog, bool) {
+	payload := entry.GetProtoPayload()
+	if payload == nil {
+		slog.Warn("unexpected payload type")
+		return nil, false
+	}
+
+	msg, err := payload.UnmarshalNew()
+	if err != nil {
+		slog.Warn("failed to unmarshal payload", slog.Any("error", err))
+		return nil, false
+	}
+
+	auditLog, ok := msg.(*audit.AuditLog)
+	if !ok {
+		slog.Warn("unexpected payload type", slog.Any("type", fmt.Sprintf("%T", msg)))
+		return nil, false
+	}
+	return auditLog, true
+}
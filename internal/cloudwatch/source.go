@@ -0,0 +1,247 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kubeaudit"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+const (
+	defaultPollInterval = time.Second * 10
+	// auditLogStreamPrefix is the prefix of the log streams EKS writes kubernetes API server audit events to
+	auditLogStreamPrefix = "kube-apiserver-audit"
+	// lookback is how far before the cursor each poll starts. CloudWatch Logs does not guarantee that events are
+	// searchable in timestamp order, so polling overlaps to pick up late arrivals; events already handled are dropped.
+	lookback = time.Minute * 5
+	// seenTTL is how long handled events are remembered for, which must exceed lookback
+	seenTTL = time.Hour
+)
+
+type store interface {
+	GetCheckpoint(name string) ([]byte, error)
+	SaveCheckpoint(name string, value []byte) error
+	HasSeen(key string) (bool, error)
+	MarkSeen(key string, ttl time.Duration) error
+}
+
+// Config identifies the EKS cluster whose audit logs are followed, and how CloudWatch Logs is reached
+type Config struct {
+	ClusterName string
+	// LogGroupName defaults to /aws/eks/<cluster>/cluster, where EKS writes control plane logs
+	LogGroupName string
+	// Region defaults to that resolved from the environment or shared configuration
+	Region string
+	// Endpoint optionally overrides the CloudWatch Logs endpoint, e.g. http://localhost:4566 for LocalStack
+	Endpoint     string
+	PollInterval time.Duration
+}
+
+func (c Config) logGroupName() string {
+	if c.LogGroupName != "" {
+		return c.LogGroupName
+	}
+	return fmt.Sprintf("/aws/eks/%s/cluster", c.ClusterName)
+}
+
+// Source is a source.Source implementation backed by EKS control plane audit logs, obtained by periodically searching
+// CloudWatch Logs (FilterLogEvents). Credentials are resolved via the default AWS credential chain.
+type Source struct {
+	config Config
+	store  store
+	filter *auditfilter.Filter
+}
+
+// NewSource instantiates and returns Source. The timestamp cursor, and the events recently handled, are persisted to
+// the supplied store. Events not matched by the filter are discarded.
+func NewSource(config Config, store store, filter *auditfilter.Filter) *Source {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	return &Source{
+		config: config,
+		store:  store,
+		filter: filter,
+	}
+}
+
+// Run polls the audit log streams, passing successful operations against fluxcd resources to the handler. If no
+// cursor has been persisted, polling starts from the current time.
+func (s *Source) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	var opts []func(*awsconfig.LoadOptions) error
+	if s.config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(s.config.Region))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	client := cloudwatchlogs.NewFromConfig(awsConfig, func(o *cloudwatchlogs.Options) {
+		if s.config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s.config.Endpoint)
+		}
+	})
+
+	p := &poller{
+		client:     client,
+		logGroup:   s.config.logGroupName(),
+		checkpoint: fmt.Sprintf("cloudwatch:%s", s.config.logGroupName()),
+		store:      s.store,
+		filter:     s.filter,
+		handle:     handle,
+	}
+
+	cursor, err := p.loadCursor()
+	if err != nil {
+		return err
+	}
+	if cursor.IsZero() {
+		cursor = time.Now().UTC()
+	}
+	p.cursor = cursor
+
+	slog.Info("polling audit log events", slog.String("logGroup", p.logGroup), slog.Time("since", cursor))
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err = p.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type poller struct {
+	client     *cloudwatchlogs.Client
+	logGroup   string
+	checkpoint string
+	store      store
+	filter     *auditfilter.Filter
+	handle     source.Handler
+	cursor     time.Time
+}
+
+// poll searches for and handles all events logged since shortly before the cursor. Failures to search are logged,
+// rather than returned, as they will be retried by the next poll.
+func (p *poller) poll(ctx context.Context) error {
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(p.client, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String(p.logGroup),
+		LogStreamNamePrefix: aws.String(auditLogStreamPrefix),
+		FilterPattern:       aws.String(filterPattern(p.filter)),
+		StartTime:           aws.Int64(p.cursor.Add(-lookback).UnixMilli()),
+	})
+
+	var count int
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			slog.Warn("filter log events failed, will retry", slog.Any("error", err))
+			return nil
+		}
+		for _, logEvent := range page.Events {
+			handled, err := p.handleLogEvent(ctx, logEvent)
+			if err != nil {
+				return err
+			}
+			if handled {
+				count++
+			}
+		}
+		if err = p.saveCursor(); err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("polled audit log events", slog.Int("events", count))
+	return nil
+}
+
+// handleLogEvent decodes a single audit event, and passes it to the handler if relevant and not already handled. True
+// is returned if the handler was invoked.
+func (p *poller) handleLogEvent(ctx context.Context, logEvent types.FilteredLogEvent) (bool, error) {
+	if ts := time.UnixMilli(aws.ToInt64(logEvent.Timestamp)).UTC(); ts.After(p.cursor) {
+		p.cursor = ts
+	}
+
+	var event kubeaudit.Event
+	if err := json.Unmarshal([]byte(aws.ToString(logEvent.Message)), &event); err != nil {
+		slog.Warn("failed to unmarshal audit event", slog.Any("error", err), slog.String("eventId", aws.ToString(logEvent.EventId)))
+		return false, nil
+	}
+	if !event.IsFluxMutation(p.filter) {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("cloudwatch:%s", aws.ToString(logEvent.EventId))
+	seen, err := p.store.HasSeen(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate: %w", err)
+	}
+	if seen {
+		return false, nil
+	}
+
+	if err = p.handle(ctx, event.SourceEvent()); err != nil {
+		return false, fmt.Errorf("handler failed: %w", err)
+	}
+
+	if err = p.store.MarkSeen(key, seenTTL); err != nil {
+		return false, fmt.Errorf("failed to mark event as seen: %w", err)
+	}
+	return true, nil
+}
+
+func (p *poller) loadCursor() (time.Time, error) {
+	value, err := p.store.GetCheckpoint(p.checkpoint)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	millis, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return time.UnixMilli(millis).UTC(), nil
+}
+
+func (p *poller) saveCursor() error {
+	if err := p.store.SaveCheckpoint(p.checkpoint, []byte(strconv.FormatInt(p.cursor.UnixMilli(), 10))); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// filterPattern narrows events server-side to completed operations against resources in the API groups of interest.
+// The remaining filtering is applied client-side.
+func filterPattern(filter *auditfilter.Filter) string {
+	groups := filter.APIGroups()
+	clauses := make([]string, 0, len(groups))
+	for _, group := range groups {
+		clauses = append(clauses, fmt.Sprintf(`($.objectRef.apiGroup = "%s")`, group))
+	}
+	return fmt.Sprintf(`{ ($.stage = "ResponseComplete") && (%s) }`, strings.Join(clauses, " || "))
+}
@@ -0,0 +1,39 @@
+package cloudwatch
+
+import (
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+)
+
+func TestFilterPattern(t *testing.T) {
+	filter, err := auditfilter.New(nil, nil, nil, []string{"keda.sh", "*.acme.io"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *auditfilter.Filter
+		want   string
+	}{
+		{
+			name:   "default filter",
+			filter: auditfilter.Default(),
+			want:   `{ ($.stage = "ResponseComplete") && (($.objectRef.apiGroup = "*.toolkit.fluxcd.io")) }`,
+		},
+		{
+			name:   "additional api groups",
+			filter: filter,
+			want: `{ ($.stage = "ResponseComplete") && (($.objectRef.apiGroup = "*.toolkit.fluxcd.io") || ` +
+				`($.objectRef.apiGroup = "keda.sh") || ($.objectRef.apiGroup = "*.acme.io")) }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterPattern(tt.filter); got != tt.want {
+				t.Errorf("filterPattern() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,16 +4,37 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Config is the application configuration
+// Supported Cloud Logging modes, used by the GKE source
+const (
+	CloudLoggingModeStream = "stream"
+	CloudLoggingModePoll   = "poll"
+)
+
+// Supported audit event sources
+const (
+	SourceGKE          = "gke"
+	SourceAuditWebhook = "auditWebhook"
+	SourceAuditFile    = "auditFile"
+	SourceInformer     = "informer"
+	SourcePubSub       = "pubSub"
+	SourceEKS          = "eks"
+	SourceKafka        = "kafka"
+	SourceOpenSearch   = "openSearch"
+)
+
+// Config is the application configuration. A single cluster may be configured via the top level cluster fields, or
+// multiple clusters via the clusters list. Once parsed, Clusters always holds the clusters to watch.
 type Config struct {
-	GoogleCloudProjectID string `yaml:"googleCloudProjectId"`
-	GKEClusterName       string `yaml:"gkeClusterName"`
-	BadgerPath           string `yaml:"badgerPath"`
-	KubernetesConfigPath string `yaml:"kubernetesConfigPath,omitempty"`
+	Cluster    `yaml:",inline"`
+	Clusters   []Cluster `yaml:"clusters,omitempty"`
+	BadgerPath string    `yaml:"badgerPath"`
+	// MetricsListenAddress optionally enables a Prometheus metrics endpoint (/metrics) on the supplied address
+	MetricsListenAddress string `yaml:"metricsListenAddress,omitempty"`
 	Notification         struct {
 		Slack []struct {
 			Filter     string `yaml:"filter,omitempty"`
@@ -22,6 +43,122 @@ type Config struct {
 	} `yaml:"notification"`
 }
 
+// Cluster is the configuration for a single cluster to be watched
+type Cluster struct {
+	// Name identifies the cluster in notifications and in the store. When listed in clusters it defaults to the GKE
+	// cluster name, and must be unique.
+	Name                 string `yaml:"name,omitempty"`
+	GoogleCloudProjectID string `yaml:"googleCloudProjectId"`
+	GKEClusterName       string `yaml:"gkeClusterName"`
+	KubernetesConfigPath string `yaml:"kubernetesConfigPath,omitempty"`
+	KubernetesContext    string `yaml:"kubernetesContext,omitempty"`
+	// LogBucket optionally names the log bucket view audit logs are read from, in the form
+	// projects/PROJECT/locations/LOCATION/buckets/BUCKET/views/VIEW. By default, the project is used.
+	LogBucket string `yaml:"logBucket,omitempty"`
+	// ReconcileInterval optionally enables a periodic comparison of every suspendable resource in the cluster against
+	// the stored state, to detect changes that were missed by the source
+	ReconcileInterval time.Duration `yaml:"reconcileInterval,omitempty"`
+	// AuditFilter determines which audit events are of interest. Unset verbs and excluded principals fall back to
+	// patch/create/delete, and the fluxcd controllers respectively. API groups are of interest in addition to the
+	// fluxcd toolkit and those selected for discovery.
+	AuditFilter struct {
+		Verbs              []string `yaml:"verbs,omitempty"`
+		ExcludedPrincipals []string `yaml:"excludedPrincipals,omitempty"`
+		IncludedPrincipals []string `yaml:"includedPrincipals,omitempty"`
+		APIGroups          []string `yaml:"apiGroups,omitempty"`
+	} `yaml:"auditFilter,omitempty"`
+	// Discovery determines which custom resource definitions are considered for suspendable resource types. Unset
+	// label selectors fall back to those of fluxcd. Types are included and excluded in the form group/kind.
+	Discovery struct {
+		LabelSelectors []string `yaml:"labelSelectors,omitempty"`
+		APIGroups      []string `yaml:"apiGroups,omitempty"`
+		Include        []string `yaml:"include,omitempty"`
+		Exclude        []string `yaml:"exclude,omitempty"`
+	} `yaml:"discovery,omitempty"`
+	// SuspendableTypes declares resource types other than those of fluxcd whose suspension is of interest, and how it
+	// is expressed: the field (as a JSON pointer) or annotation, and the value that means suspended
+	SuspendableTypes []struct {
+		Group               string `yaml:"group"`
+		Version             string `yaml:"version"`
+		Resource            string `yaml:"resource"`
+		Path                string `yaml:"path,omitempty"`
+		Annotation          string `yaml:"annotation,omitempty"`
+		SuspendedValue      any    `yaml:"suspendedValue,omitempty"`
+		SuspendedWhenAbsent bool   `yaml:"suspendedWhenAbsent,omitempty"`
+	} `yaml:"suspendableTypes,omitempty"`
+	Source       string `yaml:"source,omitempty"`
+	CloudLogging struct {
+		Mode         string        `yaml:"mode,omitempty"`
+		PollInterval time.Duration `yaml:"pollInterval,omitempty"`
+		PageSize     int64         `yaml:"pageSize,omitempty"`
+		// Credentials and billing settings, which also apply to the Pub/Sub source. Application default credentials
+		// are used when unset.
+		CredentialsFile           string `yaml:"credentialsFile,omitempty"`
+		ImpersonateServiceAccount string `yaml:"impersonateServiceAccount,omitempty"`
+		QuotaProject              string `yaml:"quotaProject,omitempty"`
+		// Endpoint optionally overrides the Cloud Logging endpoint (host:port). Insecure disables TLS and
+		// authentication, for use with a local fake server.
+		Endpoint string `yaml:"endpoint,omitempty"`
+		Insecure bool   `yaml:"insecure,omitempty"`
+	} `yaml:"cloudLogging,omitempty"`
+	PubSub struct {
+		ProjectID      string `yaml:"projectId,omitempty"`
+		SubscriptionID string `yaml:"subscriptionId"`
+	} `yaml:"pubSub,omitempty"`
+	AuditWebhook struct {
+		ListenAddress string `yaml:"listenAddress"`
+		Path          string `yaml:"path,omitempty"`
+		// TLS certificate and key served to the API server, and the CA that signs its client certificate
+		CertFile     string `yaml:"certFile,omitempty"`
+		KeyFile      string `yaml:"keyFile,omitempty"`
+		ClientCAFile string `yaml:"clientCAFile,omitempty"`
+	} `yaml:"auditWebhook,omitempty"`
+	AuditFile struct {
+		Path string `yaml:"path"`
+	} `yaml:"auditFile,omitempty"`
+	// EKS configures the CloudWatch Logs source. The cluster name defaults to the name of the cluster, and the log
+	// group to the one EKS writes control plane logs to.
+	EKS struct {
+		ClusterName  string        `yaml:"clusterName,omitempty"`
+		LogGroupName string        `yaml:"logGroupName,omitempty"`
+		Region       string        `yaml:"region,omitempty"`
+		Endpoint     string        `yaml:"endpoint,omitempty"`
+		PollInterval time.Duration `yaml:"pollInterval,omitempty"`
+	} `yaml:"eks,omitempty"`
+	Kafka struct {
+		Brokers []string `yaml:"brokers"`
+		Topic   string   `yaml:"topic"`
+		GroupID string   `yaml:"groupId"`
+		TLS     bool     `yaml:"tls,omitempty"`
+		SASL    struct {
+			Mechanism string `yaml:"mechanism,omitempty"`
+			Username  string `yaml:"username,omitempty"`
+			Password  string `yaml:"password,omitempty"`
+		} `yaml:"sasl,omitempty"`
+	} `yaml:"kafka,omitempty"`
+	OpenSearch struct {
+		URL                string        `yaml:"url"`
+		Username           string        `yaml:"username,omitempty"`
+		Password           string        `yaml:"password,omitempty"`
+		InsecureSkipVerify bool          `yaml:"insecureSkipVerify,omitempty"`
+		Index              string        `yaml:"index"`
+		Query              string        `yaml:"query,omitempty"`
+		EventField         string        `yaml:"eventField,omitempty"`
+		TimestampField     string        `yaml:"timestampField,omitempty"`
+		TiebreakerField    string        `yaml:"tiebreakerField,omitempty"`
+		PageSize           int           `yaml:"pageSize,omitempty"`
+		PollInterval       time.Duration `yaml:"pollInterval,omitempty"`
+	} `yaml:"openSearch,omitempty"`
+}
+
+// DisplayName returns the name used to identify the cluster in notifications
+func (c Cluster) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.GKEClusterName
+}
+
 // ParseFile parses configuration from a given file path
 func ParseFile(path string) (Config, error) {
 	f, err := os.Open(path)
@@ -39,5 +176,34 @@ func Parse(r io.Reader) (Config, error) {
 	if err := yaml.NewDecoder(r).Decode(&config); err != nil {
 		return Config{}, fmt.Errorf("failed to parse config file: %w", err)
 	}
+
+	if len(config.Clusters) == 0 {
+		// Single cluster configuration; the name is left empty so that store keys remain as they always have been
+		config.Clusters = []Cluster{config.Cluster}
+	} else {
+		names := make(map[string]struct{}, len(config.Clusters))
+		for i := range config.Clusters {
+			if config.Clusters[i].Name == "" {
+				config.Clusters[i].Name = config.Clusters[i].GKEClusterName
+			}
+			name := config.Clusters[i].Name
+			if name == "" {
+				return Config{}, fmt.Errorf("cluster %d has no name", i)
+			}
+			if _, ok := names[name]; ok {
+				return Config{}, fmt.Errorf("duplicate cluster name: %s", name)
+			}
+			names[name] = struct{}{}
+		}
+	}
+
+	for i := range config.Clusters {
+		if config.Clusters[i].Source == "" {
+			config.Clusters[i].Source = SourceGKE
+		}
+		if config.Clusters[i].CloudLogging.Mode == "" {
+			config.Clusters[i].CloudLogging.Mode = CloudLoggingModeStream
+		}
+	}
 	return config, nil
 }
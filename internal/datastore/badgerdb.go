@@ -8,24 +8,63 @@ import (
 
 	"github.com/dgraph-io/badger/v4"
 
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
 )
 
 // ErrNotFound is returned when an entry cannot be found in the underlying store
 var ErrNotFound = errors.New("not found")
 
-// Store is a basic badgerdb backed persistence mechanism
+// tombstoneTTL is how long entries for deleted resources are retained for
+const tombstoneTTL = time.Hour * 24 * 30
+
+// Store is a basic badgerdb backed persistence mechanism. A store may be scoped to a cluster via ForCluster, so that
+// the same resource in multiple clusters does not collide.
 type Store struct {
-	db *badger.DB
+	db      *badger.DB
+	cluster string
 }
 
 // Entry represents a single item held by the store. It relates to a single resource reference, and holds information
 // about its suspension status.
 type Entry struct {
-	Resource  k8s.ResourceReference `json:"resource"`
-	Suspended bool                  `json:"suspended"`
-	UpdatedBy string                `json:"updatedBy"`
-	UpdatedAt time.Time             `json:"updatedAt"`
+	Cluster  string                `json:"cluster,omitempty"`
+	Resource k8s.ResourceReference `json:"resource"`
+	// UID identifies the instance of the resource, where known, so that a recreated resource can be recognised
+	UID       string `json:"uid,omitempty"`
+	Suspended bool   `json:"suspended"`
+	UpdatedBy string `json:"updatedBy"`
+	// UpdatedAt is when the suspension status was last changed, according to the audit event
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Actor holds the full attribution of the last change, including any impersonation or delegation
+	Actor actor.Actor `json:"actor"`
+	// ObservedAt is the time of the most recent event or observation applied to the entry, whether or not it changed
+	// the suspension status. It is used to reject events older than the stored state.
+	ObservedAt time.Time `json:"observedAt,omitempty"`
+	// ResourceVersion and Generation are those of the object observed at ObservedAt, where known. They are cleared by
+	// observations that do not carry them, so that a generation is never compared against a later observation.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+	// Tombstoned is true if the resource has been deleted. Suspended holds the status at the time of deletion, and
+	// UpdatedBy/Actor the identity that deleted it.
+	Tombstoned bool      `json:"tombstoned,omitempty"`
+	DeletedAt  time.Time `json:"deletedAt,omitempty"`
+}
+
+// IsNewerThan returns true if the entry records a state newer than that observed at the supplied time, with the
+// supplied generation. The generation is compared when both are known, as it increases with every change to the spec
+// (including suspend), and so is unaffected by clock differences. Otherwise, or if the generations are equal (as
+// changes outside of the spec, such as to annotations, do not increase it), the observation times are compared. The
+// stored generation is only known if it was observed at the stored observation time.
+func (e Entry) IsNewerThan(generation int64, observedAt time.Time) bool {
+	if generation > 0 && e.Generation > 0 && generation != e.Generation {
+		return e.Generation > generation
+	}
+	lastObservedAt := e.ObservedAt
+	if lastObservedAt.IsZero() {
+		lastObservedAt = e.UpdatedAt // Entries saved before observation times were recorded
+	}
+	return lastObservedAt.After(observedAt)
 }
 
 // NewBadgerStore instantiates a Store instance. Data will be persisted the directory pointed at by the supplied path.
@@ -42,11 +81,31 @@ func NewBadgerStore(path string) (*Store, error) {
 	}, nil
 }
 
+// NewInMemoryBadgerStore instantiates a Store instance that is held in memory only. This is useful as a scratch store.
+func NewInMemoryBadgerStore() (*Store, error) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open badger store: %w", err)
+	}
+	return &Store{
+		db: db,
+	}, nil
+}
+
+// ForCluster returns a view of the store scoped to the named cluster. The underlying database is shared, so the
+// returned store must not be closed.
+func (s *Store) ForCluster(cluster string) *Store {
+	return &Store{
+		db:      s.db,
+		cluster: cluster,
+	}
+}
+
 // GetEntry retrieves an entry
 func (s *Store) GetEntry(resource k8s.ResourceReference) (Entry, error) {
 	var entry Entry
 	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(buildKey(resource))
+		item, err := txn.Get(s.buildKey(resource))
 		if err != nil {
 			if errors.Is(err, badger.ErrKeyNotFound) {
 				return ErrNotFound
@@ -65,14 +124,93 @@ func (s *Store) GetEntry(resource k8s.ResourceReference) (Entry, error) {
 	return entry, err
 }
 
-// SaveEntry creates or replaces an entry
+// SaveEntry creates or replaces an entry. The entry is attributed to the cluster the store is scoped to. Tombstoned
+// entries expire after 30 days.
 func (s *Store) SaveEntry(entry Entry) error {
+	entry.Cluster = s.cluster
 	return s.db.Update(func(txn *badger.Txn) error {
 		data, err := json.Marshal(entry)
 		if err != nil {
 			return fmt.Errorf("failed ot marshal entry: %w", err)
 		}
-		return txn.Set(buildKey(entry.Resource), data)
+		e := badger.NewEntry(s.buildKey(entry.Resource), data)
+		if entry.Tombstoned {
+			e = e.WithTTL(tombstoneTTL)
+		}
+		return txn.SetEntry(e)
+	})
+}
+
+// Entries retrieves all entries held for the cluster the store is scoped to, including tombstoned entries
+func (s *Store) Entries() ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: s.scopeKey("resource:")})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return fmt.Errorf("failed to get value: %w", err)
+			}
+			var entry Entry
+			if err = json.Unmarshal(val, &entry); err != nil {
+				return fmt.Errorf("failed to unmarshal entry: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// GetCheckpoint retrieves a named checkpoint value. Checkpoints are used by audit event sources to persist how far
+// through their input they have progressed.
+func (s *Store) GetCheckpoint(name string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.buildCheckpointKey(name))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to get item: %w", err)
+		}
+		value, err = item.ValueCopy(nil)
+		if err != nil {
+			return fmt.Errorf("failed to get value: %w", err)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// SaveCheckpoint creates or replaces a named checkpoint value
+func (s *Store) SaveCheckpoint(name string, value []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.buildCheckpointKey(name), value)
+	})
+}
+
+// HasSeen returns true if the key has been marked as seen, and the mark has not yet expired
+func (s *Store) HasSeen(key string) (bool, error) {
+	err := s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(s.buildSeenKey(key))
+		return err
+	})
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to get item: %w", err)
+	}
+	return true, nil
+}
+
+// MarkSeen marks the key as seen for the supplied duration. Marks expire, so that the set of seen keys is bounded.
+func (s *Store) MarkSeen(key string, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(s.buildSeenKey(key), nil).WithTTL(ttl))
 	})
 }
 
@@ -81,6 +219,23 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-func buildKey(resource k8s.ResourceReference) []byte {
-	return []byte(fmt.Sprintf("resource:%s:%s:%s:%s", resource.Type.Group, resource.Type.Kind, resource.Namespace, resource.Name))
+func (s *Store) buildKey(resource k8s.ResourceReference) []byte {
+	return s.scopeKey(fmt.Sprintf("resource:%s:%s:%s:%s", resource.Type.Group, resource.Type.Kind, resource.Namespace, resource.Name))
+}
+
+func (s *Store) buildCheckpointKey(name string) []byte {
+	return s.scopeKey(fmt.Sprintf("checkpoint:%s", name))
+}
+
+func (s *Store) buildSeenKey(key string) []byte {
+	return s.scopeKey(fmt.Sprintf("seen:%s", key))
+}
+
+// scopeKey prefixes the key with the cluster, if the store is scoped to one. Unscoped keys are left as they are, so
+// that single cluster deployments are unaffected.
+func (s *Store) scopeKey(key string) []byte {
+	if s.cluster == "" {
+		return []byte(key)
+	}
+	return []byte(fmt.Sprintf("cluster:%s:%s", s.cluster, key))
 }
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntry_IsNewerThan(t *testing.T) {
+	t1 := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	t3 := t2.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		entry      Entry
+		generation int64
+		observedAt time.Time
+		want       bool
+	}{
+		{
+			name:       "stored generation is newer",
+			entry:      Entry{Generation: 6, ObservedAt: t1},
+			generation: 5,
+			observedAt: t3,
+			want:       true,
+		},
+		{
+			name:       "stored generation is older",
+			entry:      Entry{Generation: 5, ObservedAt: t3},
+			generation: 6,
+			observedAt: t1,
+		},
+		{
+			name:       "generations equal, stored observation is newer",
+			entry:      Entry{Generation: 5, ObservedAt: t3},
+			generation: 5,
+			observedAt: t2,
+			want:       true,
+		},
+		{
+			name:       "generations equal, stored observation is older",
+			entry:      Entry{Generation: 5, ObservedAt: t1},
+			generation: 5,
+			observedAt: t2,
+		},
+		{
+			name:       "stored generation unknown, stored observation is newer",
+			entry:      Entry{ObservedAt: t3},
+			generation: 6,
+			observedAt: t2,
+			want:       true,
+		},
+		{
+			name:       "generation unknown, stored observation is older",
+			entry:      Entry{Generation: 5, ObservedAt: t1},
+			observedAt: t2,
+		},
+		{
+			name:       "generation unknown, stored observation is newer",
+			entry:      Entry{Generation: 5, ObservedAt: t3},
+			observedAt: t2,
+			want:       true,
+		},
+		{
+			name:       "same observation",
+			entry:      Entry{ObservedAt: t2},
+			observedAt: t2,
+		},
+		{
+			name:       "observation time not recorded",
+			entry:      Entry{UpdatedAt: t3},
+			observedAt: t2,
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.IsNewerThan(tt.generation, tt.observedAt); got != tt.want {
+				t.Errorf("IsNewerThan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
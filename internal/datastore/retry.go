@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// failureTTL is how long permanent failures are retained for
+const failureTTL = time.Hour * 24 * 30
+
+// Retry is an event that failed to be handled due to a transient error, and is queued to be retried
+type Retry struct {
+	ID          string       `json:"id"`
+	Event       source.Event `json:"event"`
+	Attempts    int          `json:"attempts"`
+	NextAttempt time.Time    `json:"nextAttempt"`
+	LastError   string       `json:"lastError"`
+}
+
+// Failure records an event that could not be handled, either due to a permanent error or because retries were
+// exhausted
+type Failure struct {
+	Cluster  string       `json:"cluster,omitempty"`
+	Event    source.Event `json:"event"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failedAt"`
+}
+
+// SaveRetry creates or replaces a queued retry
+func (s *Store) SaveRetry(retry Retry) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(retry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal retry: %w", err)
+		}
+		return txn.Set(s.buildRetryKey(retry.ID), data)
+	})
+}
+
+// DeleteRetry removes a queued retry
+func (s *Store) DeleteRetry(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(s.buildRetryKey(id))
+	})
+}
+
+// DueRetries returns the queued retries whose next attempt is due at the supplied time, ordered by when they were
+// queued
+func (s *Store) DueRetries(now time.Time) ([]Retry, error) {
+	var retries []Retry
+	err := s.db.View(func(txn *badger.Txn) error {
+		prefix := s.buildRetryKey("")
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return fmt.Errorf("failed to get value: %w", err)
+			}
+			var retry Retry
+			if err = json.Unmarshal(val, &retry); err != nil {
+				return fmt.Errorf("failed to unmarshal retry: %w", err)
+			}
+			if !retry.NextAttempt.After(now) {
+				retries = append(retries, retry)
+			}
+		}
+		return nil
+	})
+	return retries, err
+}
+
+// SaveFailure records an event that could not be handled. Failures expire after 30 days.
+func (s *Store) SaveFailure(failure Failure) error {
+	failure.Cluster = s.cluster
+	return s.db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(failure)
+		if err != nil {
+			return fmt.Errorf("failed to marshal failure: %w", err)
+		}
+		key := s.scopeKey(fmt.Sprintf("failure:%s:%s", failure.FailedAt.Format(time.RFC3339Nano), failure.Event.Resource.Path()))
+		return txn.SetEntry(badger.NewEntry(key, data).WithTTL(failureTTL))
+	})
+}
+
+func (s *Store) buildRetryKey(id string) []byte {
+	return s.scopeKey(fmt.Sprintf("retry:%s", id))
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultLabelSelectors selects the custom resource definitions installed by fluxcd
+var DefaultLabelSelectors = []string{"app.kubernetes.io/part-of=flux"}
+
+// Selector determines which custom resource definitions are considered when resolving suspendable resource types. A
+// definition is selected if it matches any of the label selectors or API groups, or is explicitly included, and is not
+// excluded.
+type Selector struct {
+	labelSelectors []labels.Selector
+	groups         []string
+	include        []groupKind
+	exclude        []groupKind
+}
+
+// groupKind identifies a resource type, irrespective of version. The kind may be either the kind or the plural
+// resource name.
+type groupKind struct {
+	group string
+	kind  string
+}
+
+// New instantiates and returns a Selector. Included and excluded types are given in the form group/kind (e.g.
+// infra.contrib.fluxcd.io/Terraform), where the kind may also be the plural resource name. Nil label selectors fall
+// back to the default.
+func New(labelSelectors, groups, include, exclude []string) (*Selector, error) {
+	if labelSelectors == nil {
+		labelSelectors = DefaultLabelSelectors
+	}
+
+	selectors := make([]labels.Selector, 0, len(labelSelectors))
+	for _, raw := range labelSelectors {
+		selector, err := labels.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+		selectors = append(selectors, selector)
+	}
+	includeKinds, err := parseGroupKinds(include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid included type: %w", err)
+	}
+	excludeKinds, err := parseGroupKinds(exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid excluded type: %w", err)
+	}
+
+	return &Selector{
+		labelSelectors: selectors,
+		groups:         groups,
+		include:        includeKinds,
+		exclude:        excludeKinds,
+	}, nil
+}
+
+// Default returns a selector with the default label selectors
+func Default() *Selector {
+	s, err := New(nil, nil, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// APIGroups returns the API groups that are explicitly selected, either as a whole or via an included type. Groups
+// selected only via labels are not known until definitions are listed.
+func (s *Selector) APIGroups() []string {
+	groups := slices.Clone(s.groups)
+	for _, gk := range s.include {
+		if !slices.Contains(groups, gk.group) {
+			groups = append(groups, gk.group)
+		}
+	}
+	return groups
+}
+
+// Matches returns true if the custom resource definition is selected
+func (s *Selector) Matches(crd v1.CustomResourceDefinition) bool {
+	if slices.ContainsFunc(s.exclude, func(gk groupKind) bool { return gk.matches(crd) }) {
+		return false
+	}
+	if slices.ContainsFunc(s.include, func(gk groupKind) bool { return gk.matches(crd) }) {
+		return true
+	}
+	if slices.Contains(s.groups, crd.Spec.Group) {
+		return true
+	}
+	set := labels.Set(crd.GetLabels())
+	return slices.ContainsFunc(s.labelSelectors, func(selector labels.Selector) bool {
+		return selector.Matches(set)
+	})
+}
+
+func (gk groupKind) matches(crd v1.CustomResourceDefinition) bool {
+	if gk.group != crd.Spec.Group {
+		return false
+	}
+	return strings.EqualFold(gk.kind, crd.Spec.Names.Kind) || strings.EqualFold(gk.kind, crd.Spec.Names.Plural)
+}
+
+func parseGroupKinds(raw []string) ([]groupKind, error) {
+	parsed := make([]groupKind, 0, len(raw))
+	for _, r := range raw {
+		group, kind, ok := strings.Cut(r, "/")
+		if !ok || group == "" || kind == "" {
+			return nil, fmt.Errorf("%s is not in the form group/kind", r)
+		}
+		parsed = append(parsed, groupKind{group: group, kind: kind})
+	}
+	return parsed, nil
+}
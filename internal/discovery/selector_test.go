@@ -0,0 +1,131 @@
+package discovery
+
+import (
+	"slices"
+	"testing"
+
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelector_Matches(t *testing.T) {
+	selector, err := New(
+		nil,
+		[]string{"keda.sh"},
+		[]string{"infra.contrib.fluxcd.io/Terraform", "apps.acme.io/rollouts"},
+		[]string{"kustomize.toolkit.fluxcd.io/Kustomization", "keda.sh/scaledjobs"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		crd  v1.CustomResourceDefinition
+		want bool
+	}{
+		{
+			name: "matches label",
+			crd:  crd("source.toolkit.fluxcd.io", "GitRepository", "gitrepositories", "app.kubernetes.io/part-of", "flux"),
+			want: true,
+		},
+		{
+			name: "matches no label",
+			crd:  crd("source.toolkit.fluxcd.io", "GitRepository", "gitrepositories", "app.kubernetes.io/part-of", "other"),
+		},
+		{
+			name: "matches group",
+			crd:  crd("keda.sh", "ScaledObject", "scaledobjects", "", ""),
+			want: true,
+		},
+		{
+			name: "included by kind",
+			crd:  crd("infra.contrib.fluxcd.io", "Terraform", "terraforms", "", ""),
+			want: true,
+		},
+		{
+			name: "included by plural, ignoring case",
+			crd:  crd("apps.acme.io", "Rollout", "Rollouts", "", ""),
+			want: true,
+		},
+		{
+			name: "included kind of another group",
+			crd:  crd("infra.acme.io", "Terraform", "terraforms", "", ""),
+		},
+		{
+			name: "excluded despite label",
+			crd:  crd("kustomize.toolkit.fluxcd.io", "Kustomization", "kustomizations", "app.kubernetes.io/part-of", "flux"),
+		},
+		{
+			name: "excluded despite group",
+			crd:  crd("keda.sh", "ScaledJob", "scaledjobs", "", ""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selector.Matches(tt.crd); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelector_APIGroups(t *testing.T) {
+	selector, err := New(nil, []string{"keda.sh"}, []string{"keda.sh/ScaledObject", "apps.acme.io/Rollout"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := selector.APIGroups(), []string{"keda.sh", "apps.acme.io"}; !slices.Equal(got, want) {
+		t.Errorf("APIGroups() = %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		labelSelectors []string
+		include        []string
+		exclude        []string
+		wantErr        bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:           "invalid label selector",
+			labelSelectors: []string{"app in (flux"},
+			wantErr:        true,
+		},
+		{
+			name:    "included type without group",
+			include: []string{"Terraform"},
+			wantErr: true,
+		},
+		{
+			name:    "excluded type without kind",
+			exclude: []string{"keda.sh/"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.labelSelectors, nil, tt.include, tt.exclude); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func crd(group, kind, plural, labelKey, labelValue string) v1.CustomResourceDefinition {
+	var crdLabels map[string]string
+	if labelKey != "" {
+		crdLabels = map[string]string{labelKey: labelValue}
+	}
+	return v1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Labels: crdLabels},
+		Spec: v1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: v1.CustomResourceDefinitionNames{Kind: kind, Plural: plural},
+		},
+	}
+}
@@ -0,0 +1,244 @@
+package fluxcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+)
+
+// Definition describes how the suspension status of a resource type is expressed, as the value of a field that means
+// suspended. fluxcd resources use spec.suspend; other resource types (e.g. a CronJob, or a paused Deployment) may be
+// declared via configuration.
+type Definition struct {
+	Type k8s.ResourceType
+	// pointer holds the unescaped segments of the JSON pointer to the field
+	pointer []string
+	// suspendedValue is the value of the field that means suspended, as decoded from JSON
+	suspendedValue any
+	// suspendedWhenAbsent treats a missing field as suspended, rather than as any other value
+	suspendedWhenAbsent bool
+}
+
+// SpecSuspend returns the definition used by fluxcd resources, where spec.suspend is true when suspended
+func SpecSuspend(t k8s.ResourceType) Definition {
+	return Definition{
+		Type:           t,
+		pointer:        []string{"spec", "suspend"},
+		suspendedValue: true,
+	}
+}
+
+// NewDefinition instantiates and returns a Definition. The field is given either as a JSON pointer (RFC 6901, e.g.
+// /spec/paused), or as the name of an annotation. A nil suspended value defaults to true for a field, or "true" for
+// an annotation, unless the type is suspended when the field is absent.
+func NewDefinition(
+	t k8s.ResourceType,
+	pointer string,
+	annotation string,
+	suspendedValue any,
+	suspendedWhenAbsent bool,
+) (Definition, error) {
+	if t.Group == "" || t.Version == "" || t.Kind == "" {
+		return Definition{}, errors.New("group, version and resource must be supplied")
+	}
+
+	def := Definition{
+		Type:                t,
+		suspendedWhenAbsent: suspendedWhenAbsent,
+	}
+	switch {
+	case pointer != "" && annotation != "":
+		return Definition{}, errors.New("only one of path or annotation may be supplied")
+	case annotation != "":
+		def.pointer = []string{"metadata", "annotations", annotation}
+		if suspendedValue == nil && !suspendedWhenAbsent {
+			suspendedValue = "true"
+		}
+	case strings.HasPrefix(pointer, "/") && len(pointer) > 1:
+		for _, segment := range strings.Split(pointer[1:], "/") {
+			def.pointer = append(def.pointer, strings.NewReplacer("~1", "/", "~0", "~").Replace(segment))
+		}
+		if suspendedValue == nil && !suspendedWhenAbsent {
+			suspendedValue = true
+		}
+	default:
+		return Definition{}, fmt.Errorf("invalid path: %q", pointer)
+	}
+
+	// The value is normalised to its JSON decoded form, so that it can be compared against decoded resources
+	if suspendedValue != nil {
+		data, err := json.Marshal(suspendedValue)
+		if err != nil {
+			return Definition{}, fmt.Errorf("invalid suspended value: %w", err)
+		}
+		if err = json.Unmarshal(data, &def.suspendedValue); err != nil {
+			return Definition{}, fmt.Errorf("invalid suspended value: %w", err)
+		}
+	}
+	return def, nil
+}
+
+// DefinitionFor returns the definition for the resource type, irrespective of version, or SpecSuspend if none of the
+// supplied definitions relate to it
+func DefinitionFor(definitions []Definition, t k8s.ResourceType) Definition {
+	for _, def := range definitions {
+		if def.Type.Group == t.Group && def.Type.Kind == t.Kind {
+			return def
+		}
+	}
+	return SpecSuspend(t)
+}
+
+// Path returns the field holding the suspension status, as a JSON pointer
+func (d Definition) Path() string {
+	escaped := make([]string, 0, len(d.pointer))
+	for _, segment := range d.pointer {
+		escaped = append(escaped, strings.NewReplacer("~", "~0", "/", "~1").Replace(segment))
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// FieldPath returns the unescaped segments of the path to the field holding the suspension status
+func (d Definition) FieldPath() []string {
+	return d.pointer
+}
+
+// Decode decodes a resource, evaluating its suspension status
+func (d Definition) Decode(data []byte) (Resource, error) {
+	var resource Resource
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return resource, err
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return resource, err
+	}
+	resource.Suspended = d.IsSuspended(object)
+	return resource, nil
+}
+
+// DecodeList decodes a list of resources, as presented by the kubernetes API, evaluating their suspension statuses
+func (d Definition) DecodeList(data []byte) ([]Resource, error) {
+	var list struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	resources := make([]Resource, 0, len(list.Items))
+	for _, item := range list.Items {
+		resource, err := d.Decode(item)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// IsSuspended evaluates the suspension status of a decoded resource
+func (d Definition) IsSuspended(object map[string]any) bool {
+	value, ok := lookup(object, d.pointer)
+	return d.evaluate(value, ok && value != nil)
+}
+
+// evaluate returns the suspension status represented by the value of the field, or its absence
+func (d Definition) evaluate(value any, present bool) bool {
+	if !present {
+		return d.suspendedWhenAbsent
+	}
+	return d.suspendedValue != nil && reflect.DeepEqual(value, d.suspendedValue)
+}
+
+// RequestedSuspend inspects the body of a request made to the kubernetes API, and returns the suspension status that
+// it sets. Whole objects (create, update), merge patches, strategic merge patches and JSON patches are understood.
+// False is returned as the second value if the request does not set the field.
+func (d Definition) RequestedSuspend(body []byte) (bool, bool) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return false, false
+	}
+	if body[0] == '[' {
+		return d.jsonPatchSuspend(body)
+	}
+	return d.mergePatchSuspend(body)
+}
+
+// mergePatchSuspend handles whole objects, and merge patches. A null value removes the field, as does a null value for
+// any of its parents.
+func (d Definition) mergePatchSuspend(body []byte) (bool, bool) {
+	var patch any
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return false, false
+	}
+
+	value := patch
+	for _, segment := range d.pointer {
+		if value == nil {
+			return d.evaluate(nil, false), true
+		}
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return false, false
+		}
+		if value, ok = fields[segment]; !ok {
+			return false, false
+		}
+	}
+	return d.evaluate(value, value != nil), true
+}
+
+// jsonPatchSuspend handles JSON patches (RFC 6902). Operations against the field, or any of its parents, are
+// considered; where several do, the last one wins.
+func (d Definition) jsonPatchSuspend(body []byte) (bool, bool) {
+	var ops []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return false, false
+	}
+
+	path := d.Path()
+	var suspend, found bool
+	for _, op := range ops {
+		if op.Path != path && !strings.HasPrefix(path, op.Path+"/") {
+			continue
+		}
+		switch op.Op {
+		case "add", "replace":
+			// The parent is being set as a whole, so the field is absent unless it is held by the value
+			var value any
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return false, false
+			}
+			remaining := strings.Count(strings.TrimPrefix(path, op.Path), "/")
+			value, ok := lookup(value, d.pointer[len(d.pointer)-remaining:])
+			suspend, found = d.evaluate(value, ok && value != nil), true
+		case "remove":
+			suspend, found = d.evaluate(nil, false), true
+		}
+	}
+	return suspend, found
+}
+
+// lookup returns the value at the path within a decoded JSON document
+func lookup(document any, path []string) (any, bool) {
+	value := document
+	for _, segment := range path {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = fields[segment]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
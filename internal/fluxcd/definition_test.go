@@ -0,0 +1,164 @@
+package fluxcd
+
+import (
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+)
+
+func TestDefinition_RequestedSuspend(t *testing.T) {
+	kustomizations := k8s.ResourceType{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Kind: "kustomizations"}
+	scaledObjects := k8s.ResourceType{Group: "keda.sh", Version: "v1alpha1", Kind: "scaledobjects"}
+	deployments := k8s.ResourceType{Group: "apps", Version: "v1", Kind: "deployments"}
+
+	annotation, err := NewDefinition(scaledObjects, "", "autoscaling.keda.sh/paused", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	absent, err := NewDefinition(deployments, "/spec/replicas", "", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		definition    Definition
+		body          string
+		wantSuspended bool
+		wantOK        bool
+	}{
+		{
+			name:       "empty body",
+			definition: SpecSuspend(kustomizations),
+			body:       "",
+		},
+		{
+			name:       "invalid json",
+			definition: SpecSuspend(kustomizations),
+			body:       "{",
+		},
+		{
+			name:          "merge patch suspends",
+			definition:    SpecSuspend(kustomizations),
+			body:          `{"spec":{"suspend":true}}`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "merge patch resumes",
+			definition: SpecSuspend(kustomizations),
+			body:       `{"spec":{"suspend":false}}`,
+			wantOK:     true,
+		},
+		{
+			name:       "merge patch of another field",
+			definition: SpecSuspend(kustomizations),
+			body:       `{"spec":{"interval":"5m"}}`,
+		},
+		{
+			name:       "merge patch removes field",
+			definition: SpecSuspend(kustomizations),
+			body:       `{"spec":{"suspend":null}}`,
+			wantOK:     true,
+		},
+		{
+			name:       "merge patch removes parent",
+			definition: SpecSuspend(kustomizations),
+			body:       `{"spec":null}`,
+			wantOK:     true,
+		},
+		{
+			name:          "whole object",
+			definition:    SpecSuspend(kustomizations),
+			body:          `{"apiVersion":"kustomize.toolkit.fluxcd.io/v1","kind":"Kustomization","spec":{"suspend":true,"path":"./"}}`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:          "json patch replaces field",
+			definition:    SpecSuspend(kustomizations),
+			body:          `[{"op":"replace","path":"/spec/suspend","value":true}]`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "json patch removes field",
+			definition: SpecSuspend(kustomizations),
+			body:       `[{"op":"remove","path":"/spec/suspend"}]`,
+			wantOK:     true,
+		},
+		{
+			name:          "json patch adds parent holding field",
+			definition:    SpecSuspend(kustomizations),
+			body:          `[{"op":"add","path":"/spec","value":{"suspend":true}}]`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "json patch adds parent without field",
+			definition: SpecSuspend(kustomizations),
+			body:       `[{"op":"add","path":"/spec","value":{"interval":"5m"}}]`,
+			wantOK:     true,
+		},
+		{
+			name:          "json patch last operation wins",
+			definition:    SpecSuspend(kustomizations),
+			body:          `[{"op":"replace","path":"/spec/suspend","value":false},{"op":"replace","path":"/spec/suspend","value":true}]`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "json patch of another field",
+			definition: SpecSuspend(kustomizations),
+			body:       `[{"op":"replace","path":"/spec/suspended","value":true}]`,
+		},
+		{
+			name:          "merge patch sets annotation",
+			definition:    annotation,
+			body:          `{"metadata":{"annotations":{"autoscaling.keda.sh/paused":"true"}}}`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:          "json patch sets escaped annotation",
+			definition:    annotation,
+			body:          `[{"op":"add","path":"/metadata/annotations/autoscaling.keda.sh~1paused","value":"true"}]`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "json patch sets annotation to another value",
+			definition: annotation,
+			body:       `[{"op":"add","path":"/metadata/annotations/autoscaling.keda.sh~1paused","value":"false"}]`,
+			wantOK:     true,
+		},
+		{
+			name:          "merge patch removes field suspended when absent",
+			definition:    absent,
+			body:          `{"spec":{"replicas":null}}`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:          "merge patch sets value suspended when absent",
+			definition:    absent,
+			body:          `{"spec":{"replicas":0}}`,
+			wantSuspended: true,
+			wantOK:        true,
+		},
+		{
+			name:       "merge patch sets other value suspended when absent",
+			definition: absent,
+			body:       `{"spec":{"replicas":3}}`,
+			wantOK:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suspended, ok := tt.definition.RequestedSuspend([]byte(tt.body))
+			if suspended != tt.wantSuspended || ok != tt.wantOK {
+				t.Errorf("RequestedSuspend() = %v, %v; want %v, %v", suspended, ok, tt.wantSuspended, tt.wantOK)
+			}
+		})
+	}
+}
@@ -1,18 +1,18 @@
 package fluxcd
 
-// Resource represents an abstract suspendable fluxcd resource. Only the fields relevant to this application are
-// covered here
+import "time"
+
+// Resource represents an abstract suspendable resource. Only the fields relevant to this application are covered here
 type Resource struct {
 	Metadata struct {
-		Name      string `json:"name"`
-		Namespace string `json:"namespace"`
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		UID             string `json:"uid,omitempty"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+		Generation      int64  `json:"generation,omitempty"`
+		// DeletionTimestamp is set once the resource has been deleted, while finalizers are pending
+		DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
 	} `json:"metadata"`
-	Spec struct {
-		Suspend bool `json:"suspend"`
-	} `json:"spec"`
-}
-
-// ResourceList represents a list of resources, aligned to how this would be presented by the kubernetes API
-type ResourceList struct {
-	Items []Resource `json:"items"`
+	// Suspended is evaluated by the Definition of the resource type, when decoded
+	Suspended bool `json:"-"`
 }
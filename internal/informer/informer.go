@@ -0,0 +1,231 @@
+package informer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/fluxcd"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// UnknownManager is reported when no field manager owns the suspend field, for example when it has been removed
+const UnknownManager = actor.UnknownPrincipal
+
+// Source is a source.Source implementation that uses kubernetes watches, rather than audit logs, to observe changes.
+// As there is no audit log available, changes are attributed to the field manager that owns the suspend field (e.g.
+// spec.suspend) according to the resource managed fields (e.g. flux, kubectl-patch, kubectl-edit).
+type Source struct {
+	client      dynamic.Interface
+	definitions []fluxcd.Definition
+}
+
+// NewSource instantiates and returns Source. The definitions describe how resource types other than those of fluxcd
+// express their suspension status.
+func NewSource(client dynamic.Interface, definitions []fluxcd.Definition) *Source {
+	return &Source{
+		client:      client,
+		definitions: definitions,
+	}
+}
+
+// ScopedToTypes implements source.Scoped, as only the resource types supplied to Run are watched
+func (s *Source) ScopedToTypes() {}
+
+// Run watches the supplied resource types, and invokes the handler whenever the suspend status of a resource flips, or
+// a resource is deleted
+func (s *Source) Run(ctx context.Context, types []k8s.ResourceType, handle source.Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes := make(chan source.Event)
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(s.client, 0)
+
+	seen := make(map[string]struct{})
+	for _, t := range types {
+		// We only need to watch one version per group+kind
+		key := fmt.Sprintf("%s:%s", t.Group, t.Kind)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		_, err := factory.ForResource(t.GroupVersionResource()).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				change, ok := detectChange(fluxcd.DefinitionFor(s.definitions, t), oldObj, newObj)
+				if !ok {
+					return
+				}
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				deletion, ok := detectDeletion(t, obj)
+				if !ok {
+					return
+				}
+				select {
+				case changes <- deletion:
+				case <-ctx.Done():
+				}
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add event handler: %w", err)
+		}
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	for gvr, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %s", gvr.String())
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case change := <-changes:
+			if err := handle(ctx, change); err != nil {
+				return fmt.Errorf("handler failed: %w", err)
+			}
+		}
+	}
+}
+
+// detectChange checks whether the suspend status differs between the old and new versions of a resource. If it does,
+// an event describing the change is returned.
+func detectChange(def fluxcd.Definition, oldObj, newObj interface{}) (source.Event, bool) {
+	oldResource, ok := oldObj.(*unstructured.Unstructured)
+	if !ok {
+		return source.Event{}, false
+	}
+	newResource, ok := newObj.(*unstructured.Unstructured)
+	if !ok {
+		return source.Event{}, false
+	}
+
+	if def.IsSuspended(oldResource.Object) == def.IsSuspended(newResource.Object) {
+		return source.Event{}, false // Probably something else about the resource modified
+	}
+
+	manager, updatedAt := suspendFieldManager(def, newResource)
+	slog.Debug(
+		"suspend status change observed",
+		slog.String("kind", def.Type.Kind),
+		slog.String("resource", newResource.GetName()),
+		slog.String("manager", manager),
+	)
+
+	// The observed object is carried by the event, so that its generation is compared against the stored state
+	object, err := newResource.MarshalJSON()
+	if err != nil {
+		slog.Warn("failed to marshal resource", slog.Any("error", err), slog.String("resource", newResource.GetName()))
+		object = nil
+	}
+
+	return source.Event{
+		Resource: k8s.ResourceReference{
+			Type:      def.Type,
+			Namespace: newResource.GetNamespace(),
+			Name:      newResource.GetName(),
+		},
+		Actor: actor.Actor{
+			Principal: manager,
+		},
+		Time:   updatedAt,
+		Object: object,
+	}, true
+}
+
+// detectDeletion returns an event describing the deletion of a resource. Watches do not record who deleted a resource,
+// so the deletion is attributed to UnknownManager.
+func detectDeletion(t k8s.ResourceType, obj interface{}) (source.Event, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return source.Event{}, false
+	}
+
+	slog.Debug("deletion observed", slog.String("kind", t.Kind), slog.String("resource", resource.GetName()))
+
+	return source.Event{
+		Resource: k8s.ResourceReference{
+			Type:      t,
+			Namespace: resource.GetNamespace(),
+			Name:      resource.GetName(),
+		},
+		Actor: actor.Actor{
+			Principal: UnknownManager,
+		},
+		Time:    time.Now().UTC(),
+		Deleted: true,
+	}, true
+}
+
+// suspendFieldManager returns the field manager that most recently took ownership of the suspend field, along with the
+// time at which it did so. The current time is returned if the managed fields do not record one.
+func suspendFieldManager(def fluxcd.Definition, resource *unstructured.Unstructured) (string, time.Time) {
+	var (
+		manager string
+		latest  time.Time
+	)
+	for _, entry := range resource.GetManagedFields() {
+		if !ownsField(entry, def.FieldPath()) {
+			continue
+		}
+		var updatedAt time.Time
+		if entry.Time != nil {
+			updatedAt = entry.Time.Time
+		}
+		if manager == "" || updatedAt.After(latest) {
+			manager = entry.Manager
+			latest = updatedAt
+		}
+	}
+	if manager == "" {
+		manager = UnknownManager
+	}
+	if latest.IsZero() {
+		latest = time.Now().UTC()
+	}
+	return manager, latest
+}
+
+// ownsField returns true if the managed fields entry owns the field at the path. Fields are keyed by their name,
+// prefixed with f: (e.g. {"f:spec":{"f:suspend":{}}}).
+func ownsField(entry metav1.ManagedFieldsEntry, path []string) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+	value := json.RawMessage(entry.FieldsV1.Raw)
+	for _, segment := range path {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			slog.Warn("failed to unmarshal managed fields", slog.Any("error", err), slog.String("manager", entry.Manager))
+			return false
+		}
+		var ok bool
+		if value, ok = fields["f:"+segment]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package informer
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/fluxcd"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+)
+
+var kustomizations = k8s.ResourceType{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Kind: "kustomizations"}
+
+func TestOwnsField(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		path   []string
+		want   bool
+	}{
+		{
+			name:   "owns field",
+			fields: `{"f:spec":{"f:interval":{},"f:suspend":{}}}`,
+			path:   []string{"spec", "suspend"},
+			want:   true,
+		},
+		{
+			name:   "owns sibling",
+			fields: `{"f:spec":{"f:interval":{}}}`,
+			path:   []string{"spec", "suspend"},
+		},
+		{
+			name:   "owns annotation",
+			fields: `{"f:metadata":{"f:annotations":{"f:autoscaling.keda.sh/paused":{}}}}`,
+			path:   []string{"metadata", "annotations", "autoscaling.keda.sh/paused"},
+			want:   true,
+		},
+		{
+			name:   "malformed",
+			fields: `{"f:spec":[]}`,
+			path:   []string{"spec", "suspend"},
+		},
+		{
+			name: "no fields",
+			path: []string{"spec", "suspend"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := metav1.ManagedFieldsEntry{Manager: "kubectl-patch"}
+			if tt.fields != "" {
+				entry.FieldsV1 = &metav1.FieldsV1{Raw: []byte(tt.fields)}
+			}
+			if got := ownsField(entry, tt.path); got != tt.want {
+				t.Errorf("ownsField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuspendFieldManager(t *testing.T) {
+	t1 := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	suspend := `{"f:spec":{"f:suspend":{}}}`
+
+	tests := []struct {
+		name        string
+		fields      []metav1.ManagedFieldsEntry
+		wantManager string
+		wantTime    time.Time
+	}{
+		{
+			name: "latest owner",
+			fields: []metav1.ManagedFieldsEntry{
+				managedFields("kustomize-controller", suspend, t1),
+				managedFields("kubectl-patch", suspend, t2),
+				managedFields("flux", `{"f:spec":{"f:interval":{}}}`, t2.Add(time.Minute)),
+			},
+			wantManager: "kubectl-patch",
+			wantTime:    t2,
+		},
+		{
+			name:        "no owner",
+			fields:      []metav1.ManagedFieldsEntry{managedFields("flux", `{"f:spec":{"f:interval":{}}}`, t1)},
+			wantManager: UnknownManager,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := kustomization(false)
+			resource.SetManagedFields(tt.fields)
+
+			manager, updatedAt := suspendFieldManager(fluxcd.SpecSuspend(kustomizations), resource)
+			if manager != tt.wantManager {
+				t.Errorf("suspendFieldManager() manager = %q, want %q", manager, tt.wantManager)
+			}
+			if !tt.wantTime.IsZero() && !updatedAt.Equal(tt.wantTime) {
+				t.Errorf("suspendFieldManager() time = %s, want %s", updatedAt, tt.wantTime)
+			}
+			if tt.wantTime.IsZero() && updatedAt.IsZero() {
+				t.Error("suspendFieldManager() time is zero, want the current time")
+			}
+		})
+	}
+}
+
+func TestDetectChange(t *testing.T) {
+	updatedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	def := fluxcd.SpecSuspend(kustomizations)
+
+	suspended := kustomization(true)
+	suspended.SetManagedFields([]metav1.ManagedFieldsEntry{
+		managedFields("kubectl-patch", `{"f:spec":{"f:suspend":{}}}`, updatedAt),
+	})
+
+	tests := []struct {
+		name   string
+		oldObj any
+		newObj any
+		want   bool
+	}{
+		{name: "suspended", oldObj: kustomization(false), newObj: suspended, want: true},
+		{name: "unchanged", oldObj: kustomization(true), newObj: suspended},
+		{name: "not a resource", oldObj: "podinfo", newObj: suspended},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := detectChange(def, tt.oldObj, tt.newObj)
+			if ok != tt.want {
+				t.Fatalf("detectChange() = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			ref := event.Resource
+			if ref.Name != "podinfo" || ref.Namespace != "flux-system" || ref.Type != kustomizations {
+				t.Errorf("unexpected resource: %+v", event.Resource)
+			}
+			if event.Actor.Principal != "kubectl-patch" || !event.Time.Equal(updatedAt) {
+				t.Errorf("unexpected attribution: %s at %s", event.Actor.Principal, event.Time)
+			}
+			if event.Object == nil {
+				t.Error("expected the observed object")
+			}
+		})
+	}
+}
+
+func kustomization(suspended bool) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+		"kind":       "Kustomization",
+		"metadata":   map[string]any{"name": "podinfo", "namespace": "flux-system"},
+		"spec":       map[string]any{"suspend": suspended},
+	}}
+}
+
+func managedFields(manager, fields string, updatedAt time.Time) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:  manager,
+		Time:     &metav1.Time{Time: updatedAt},
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
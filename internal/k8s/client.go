@@ -2,34 +2,48 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"path"
 
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apiextinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 // Client is a thing wrapper around the kubernetes client. It exposes functions relevant for checking what fluxcd
 // resources exist, and what their underlying state look like.
 type Client struct {
-	client       *kubernetes.Clientset
-	apiExtClient *clientset.Clientset
+	client        *kubernetes.Clientset
+	apiExtClient  *clientset.Clientset
+	dynamicClient dynamic.Interface
 }
 
-// NewClient instantiates and returns a Client
-func NewClient(configPath string) (*Client, error) {
+// NewClient instantiates and returns a Client. If neither a config path nor a context are supplied, in-cluster
+// configuration is used. Otherwise, the supplied kubeconfig (or the default loading rules, if no path is supplied) is
+// used, optionally with the named context.
+func NewClient(configPath string, contextName string) (*Client, error) {
 	var (
 		config *rest.Config
 		err    error
 	)
-	if configPath == "" {
+	if configPath == "" && contextName == "" {
 		config, err = rest.InClusterConfig()
 	} else {
-		config, err = clientcmd.BuildConfigFromFlags("", configPath)
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = configPath
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules,
+			&clientcmd.ConfigOverrides{CurrentContext: contextName},
+		).ClientConfig()
 	}
 	if err != nil {
 		return nil, err
@@ -45,9 +59,15 @@ func NewClient(configPath string) (*Client, error) {
 		return nil, err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
-		client:       clientSet,
-		apiExtClient: apiExtClientSet,
+		client:        clientSet,
+		apiExtClient:  apiExtClientSet,
+		dynamicClient: dynamicClient,
 	}, nil
 }
 
@@ -93,3 +113,61 @@ func (c *Client) GetCustomResourceDefinitions(ctx context.Context, listOptions m
 		CustomResourceDefinitions().
 		List(ctx, listOptions)
 }
+
+// WatchCustomResourceDefinitions invokes the callback whenever a custom resource definition matching the label
+// selector is added, modified or deleted, including once for each that exists when the watch starts. It blocks until
+// the context is cancelled.
+func (c *Client) WatchCustomResourceDefinitions(ctx context.Context, labelSelector string, changed func()) error {
+	factory := apiextinformers.NewSharedInformerFactoryWithOptions(
+		c.apiExtClient,
+		0,
+		apiextinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}),
+	)
+	_, err := factory.Apiextensions().V1().CustomResourceDefinitions().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			changed()
+		},
+		UpdateFunc: func(interface{}, interface{}) {
+			changed()
+		},
+		DeleteFunc: func(interface{}) {
+			changed()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// ServesResourceType returns true if the API server serves the resource type, at its version
+func (c *Client) ServesResourceType(t ResourceType) (bool, error) {
+	resources, err := c.client.Discovery().ServerResourcesForGroupVersion(schema.GroupVersion{
+		Group:   t.Group,
+		Version: t.Version,
+	}.String())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == t.Kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DynamicClient returns a dynamic client, which can be used to list and watch arbitrary resource types
+func (c *Client) DynamicClient() dynamic.Interface {
+	return c.dynamicClient
+}
@@ -3,6 +3,8 @@ package k8s
 import (
 	"fmt"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // ResourceType represents a kubernetes resource type
@@ -12,6 +14,15 @@ type ResourceType struct {
 	Kind    string `json:"kind"`
 }
 
+// GroupVersionResource converts the resource type to its kubernetes API machinery equivalent
+func (t ResourceType) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    t.Group,
+		Version:  t.Version,
+		Resource: t.Kind,
+	}
+}
+
 // ResourceReference represents a reference to a kubernetes resource instance
 type ResourceReference struct {
 	Type      ResourceType `json:"type"`
@@ -35,3 +46,8 @@ func ResourceReferenceFromPath(path string) (ResourceReference, error) {
 		Name:      parts[5],
 	}, nil
 }
+
+// Path returns the API path of the resource reference. It is the inverse of ResourceReferenceFromPath.
+func (r ResourceReference) Path() string {
+	return fmt.Sprintf("%s/%s/namespaces/%s/%s/%s", r.Type.Group, r.Type.Version, r.Namespace, r.Type.Kind, r.Name)
+}
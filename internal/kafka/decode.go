@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kubeaudit"
+)
+
+// aksEnvelope is the envelope Azure diagnostic settings wrap resource logs in, when streaming to Event Hubs. For AKS
+// kube-audit categories, each record holds a single audit event, serialised as a string.
+type aksEnvelope struct {
+	Records []json.RawMessage `json:"records"`
+}
+
+// aksRecord is a single resource log record held by an aksEnvelope
+type aksRecord struct {
+	Category   string `json:"category"`
+	Properties struct {
+		Log string `json:"log"`
+	} `json:"properties"`
+}
+
+// decodeMessage decodes the audit events held by a message value. The value may be a single audit event (e.g. as
+// shipped by Fluent Bit), an audit event list (as sent by the API server webhook backend), or an AKS diagnostic
+// envelope.
+func decodeMessage(value []byte) ([]kubeaudit.Event, error) {
+	var probe struct {
+		Kind    string          `json:"kind"`
+		Records json.RawMessage `json:"records"`
+		Items   json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	switch {
+	case probe.Records != nil:
+		return decodeAKSEnvelope(value)
+	case probe.Kind == "EventList" || probe.Items != nil:
+		var eventList kubeaudit.EventList
+		if err := json.Unmarshal(value, &eventList); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event list: %w", err)
+		}
+		return eventList.Items, nil
+	default:
+		var event kubeaudit.Event
+		if err := json.Unmarshal(value, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		return []kubeaudit.Event{event}, nil
+	}
+}
+
+// decodeAKSEnvelope decodes the audit events held by an AKS diagnostic envelope. Records of other categories (e.g.
+// kube-apiserver, or guard) are ignored. Records that cannot be decoded are logged and skipped, so that the other
+// audit events in the envelope are not lost.
+func decodeAKSEnvelope(value []byte) ([]kubeaudit.Event, error) {
+	var envelope aksEnvelope
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	events := make([]kubeaudit.Event, 0, len(envelope.Records))
+	for i, raw := range envelope.Records {
+		var record aksRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			slog.Warn("failed to unmarshal record, skipping", slog.Any("error", err), slog.Int("record", i))
+			continue
+		}
+		if !strings.HasPrefix(record.Category, "kube-audit") {
+			continue
+		}
+		var event kubeaudit.Event
+		if err := json.Unmarshal([]byte(record.Properties.Log), &event); err != nil {
+			slog.Warn(
+				"failed to unmarshal audit event, skipping",
+				slog.Any("error", err),
+				slog.Int("record", i),
+				slog.String("category", record.Category),
+			)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kubeaudit"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "single event",
+			value:   `{"kind":"Event","auditID":"a1","verb":"patch"}`,
+			wantIDs: []string{"a1"},
+		},
+		{
+			name:    "event list",
+			value:   `{"kind":"EventList","items":[{"auditID":"a1"},{"auditID":"a2"}]}`,
+			wantIDs: []string{"a1", "a2"},
+		},
+		{
+			name:    "event list without kind",
+			value:   `{"items":[{"auditID":"a1"}]}`,
+			wantIDs: []string{"a1"},
+		},
+		{
+			name: "aks envelope",
+			value: `{"records":[
+				{"category":"kube-audit","properties":{"log":"{\"auditID\":\"a1\"}"}},
+				{"category":"kube-apiserver","properties":{"log":"I0701 12:00:00 started"}},
+				{"category":"kube-audit-admin","properties":{"log":"{\"auditID\":\"a2\"}"}}
+			]}`,
+			wantIDs: []string{"a1", "a2"},
+		},
+		{
+			name: "aks envelope with malformed records",
+			value: `{"records":[
+				"not a record",
+				{"category":"kube-audit","properties":{"log":"{"}},
+				{"category":"kube-audit","properties":{"log":"{\"auditID\":\"a2\"}"}}
+			]}`,
+			wantIDs: []string{"a2"},
+		},
+		{
+			name:    "malformed",
+			value:   `{"auditID":`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed event list",
+			value:   `{"items":{"auditID":"a1"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := decodeMessage([]byte(tt.value))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := auditIDs(events); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("decodeMessage() audit IDs = %q, want %q", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func auditIDs(events []kubeaudit.Event) []string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.AuditID)
+	}
+	return ids
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// Supported SASL mechanisms
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// Config identifies the topic audit events are consumed from, and how the brokers are reached. For Azure Event Hubs,
+// enable TLS and use the PLAIN mechanism, with the username $ConnectionString and the connection string as password.
+type Config struct {
+	Brokers       []string
+	Topic         string
+	GroupID       string
+	TLS           bool
+	SASLMechanism string
+	Username      string
+	Password      string
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	if len(c.Brokers) == 0 {
+		return errors.New("at least one broker must be supplied")
+	}
+	if c.Topic == "" {
+		return errors.New("topic must be supplied")
+	}
+	if c.GroupID == "" {
+		return errors.New("group id must be supplied")
+	}
+	_, err := c.saslMechanism()
+	return err
+}
+
+func (c Config) saslMechanism() (sasl.Mechanism, error) {
+	switch strings.ToUpper(c.SASLMechanism) {
+	case "":
+		return nil, nil
+	case SASLMechanismPlain:
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case SASLMechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case SASLMechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	default:
+		return nil, fmt.Errorf("unsupported sasl mechanism: %s", c.SASLMechanism)
+	}
+}
+
+// Source is a source.Source implementation that consumes kubernetes audit events from a Kafka topic, as a member of a
+// consumer group. This covers AKS, whose diagnostic settings can stream kube-audit logs to Event Hubs (which speaks
+// the Kafka protocol), and pipelines that ship audit logs to Kafka (e.g. Fluent Bit).
+//
+// Offsets are committed only once all events held by a message have been handled, so messages are redelivered if the
+// application stops part way through.
+type Source struct {
+	config Config
+	filter *auditfilter.Filter
+}
+
+// NewSource instantiates and returns Source. Events not matched by the filter are discarded.
+func NewSource(config Config, filter *auditfilter.Filter) *Source {
+	return &Source{
+		config: config,
+		filter: filter,
+	}
+}
+
+// Run consumes messages from the topic, passing successful operations against fluxcd resources to the handler
+func (s *Source) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	mechanism, err := s.config.saslMechanism()
+	if err != nil {
+		return err
+	}
+	dialer := &kafka.Dialer{
+		DualStack:     true,
+		SASLMechanism: mechanism,
+	}
+	if s.config.TLS {
+		dialer.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: s.config.Brokers,
+		Topic:   s.config.Topic,
+		GroupID: s.config.GroupID,
+		Dialer:  dialer,
+	})
+	defer reader.Close()
+
+	slog.Info("consuming audit events", slog.String("topic", s.config.Topic), slog.String("group", s.config.GroupID))
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("fetch message failed: %w", err)
+		}
+
+		if err = s.handleMessage(ctx, msg, handle); err != nil {
+			return err
+		}
+
+		if err = reader.CommitMessages(ctx, msg); err != nil {
+			return fmt.Errorf("commit message failed: %w", err)
+		}
+	}
+}
+
+// handleMessage decodes the audit events held by a message, and passes those that are relevant to the handler.
+// Messages that cannot be decoded are dropped, as redelivery won't help.
+func (s *Source) handleMessage(ctx context.Context, msg kafka.Message, handle source.Handler) error {
+	events, err := decodeMessage(msg.Value)
+	if err != nil {
+		slog.Warn(
+			"failed to decode message",
+			slog.Any("error", err),
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+		)
+		return nil
+	}
+
+	for _, event := range events {
+		if !event.IsFluxMutation(s.filter) {
+			continue
+		}
+		if err = handle(ctx, event.SourceEvent()); err != nil {
+			return fmt.Errorf("handler failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package kubeaudit
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// StageResponseComplete is the audit stage at which the response has been sent, and the outcome of the request is known
+const StageResponseComplete = "ResponseComplete"
+
+// Event represents a kubernetes audit event (audit.k8s.io/v1). Only the fields relevant to this application are
+// covered here
+type Event struct {
+	AuditID          string           `json:"auditID"`
+	Stage            string           `json:"stage"`
+	Verb             string           `json:"verb"`
+	User             UserInfo         `json:"user"`
+	ImpersonatedUser *UserInfo        `json:"impersonatedUser,omitempty"`
+	SourceIPs        []string         `json:"sourceIPs,omitempty"`
+	UserAgent        string           `json:"userAgent,omitempty"`
+	ObjectRef        *ObjectReference `json:"objectRef,omitempty"`
+	ResponseStatus   *ResponseStatus  `json:"responseStatus,omitempty"`
+	RequestObject    json.RawMessage  `json:"requestObject,omitempty"`
+	ResponseObject   json.RawMessage  `json:"responseObject,omitempty"`
+	StageTimestamp   time.Time        `json:"stageTimestamp"`
+}
+
+// EventList represents a list of audit events, as sent by the kubernetes API server audit webhook backend
+type EventList struct {
+	Items []Event `json:"items"`
+}
+
+// UserInfo holds information about the user that made the request
+type UserInfo struct {
+	Username string              `json:"username"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+}
+
+// extraARN is the user extra field populated by the EKS authenticator with the IAM ARN of the caller (e.g.
+// arn:aws:sts::123456789012:assumed-role/Admin/alice)
+const extraARN = "arn"
+
+// ObjectReference references the object the request was made against
+type ObjectReference struct {
+	Resource   string `json:"resource"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	APIGroup   string `json:"apiGroup"`
+	APIVersion string `json:"apiVersion"`
+}
+
+// ResponseStatus holds the status of the response sent to the client
+type ResponseStatus struct {
+	Code int `json:"code"`
+}
+
+// IsFluxMutation returns true if the event represents a completed, successful operation against a fluxcd resource,
+// where the verb, API group and user are matched by the filter. This mirrors the filter applied to GKE audit logs.
+func (e Event) IsFluxMutation(filter *auditfilter.Filter) bool {
+	if e.Stage != StageResponseComplete {
+		return false
+	}
+	if !filter.MatchesVerb(e.Verb) {
+		return false
+	}
+	if e.ObjectRef == nil || e.ObjectRef.Name == "" || !filter.MatchesAPIGroup(e.ObjectRef.APIGroup) {
+		return false
+	}
+	if e.ResponseStatus != nil && (e.ResponseStatus.Code < http.StatusOK || e.ResponseStatus.Code >= http.StatusMultipleChoices) {
+		return false
+	}
+	return filter.MatchesPrincipal(e.Actor().Principal)
+}
+
+// ResourceReference returns a reference to the resource the event relates to
+func (e Event) ResourceReference() k8s.ResourceReference {
+	return k8s.ResourceReference{
+		Type: k8s.ResourceType{
+			Group:   e.ObjectRef.APIGroup,
+			Version: e.ObjectRef.APIVersion,
+			Kind:    e.ObjectRef.Resource,
+		},
+		Namespace: e.ObjectRef.Namespace,
+		Name:      e.ObjectRef.Name,
+	}
+}
+
+// SourceEvent converts the audit event to its normalised form. When the audit policy level records the request and
+// response bodies (Request, RequestResponse), the outcome of the operation is taken from them.
+func (e Event) SourceEvent() source.Event {
+	event := source.Event{
+		Resource: e.ResourceReference(),
+		Actor:    e.Actor(),
+		Time:     e.StageTimestamp,
+		Created:  e.Verb == auditfilter.VerbCreate,
+	}
+	if e.Verb == auditfilter.VerbDelete {
+		event.Deleted = true
+		return event
+	}
+	if e.responseIsResource() {
+		event.Object = e.ResponseObject
+	}
+	if len(e.RequestObject) > 0 {
+		event.Request = e.RequestObject
+	}
+	return event
+}
+
+// responseIsResource returns true if the recorded response object is the resulting resource
+func (e Event) responseIsResource() bool {
+	if len(e.ResponseObject) == 0 {
+		return false
+	}
+	var response struct {
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(e.ResponseObject, &response); err != nil {
+		return false
+	}
+	return response.Spec != nil
+}
+
+// Actor returns the identity that made the request, including any user it impersonated. Where the user was
+// authenticated via IAM (EKS), the IAM ARN is used as the principal, as the username is often a shared mapping such as
+// kubernetes-admin. The first source IP is the originating client; any others are intermediate proxies.
+func (e Event) Actor() actor.Actor {
+	a := actor.Actor{
+		Principal: e.User.Username,
+		UserAgent: e.UserAgent,
+	}
+	if arns := e.User.Extra[extraARN]; len(arns) > 0 && arns[0] != "" {
+		a.Principal = arns[0]
+	}
+	if len(e.SourceIPs) > 0 {
+		a.CallerIP = e.SourceIPs[0]
+	}
+	if e.ImpersonatedUser != nil {
+		a.ImpersonatedUser = e.ImpersonatedUser.Username
+	}
+	return a
+}
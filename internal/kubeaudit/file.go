@@ -0,0 +1,338 @@
+package kubeaudit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+const (
+	// filePollInterval is how often the audit log file is checked for new content, rotation and truncation
+	filePollInterval = time.Second
+	// fingerprintMaxSize bounds how much of the start of the file is read to fingerprint it
+	fingerprintMaxSize = 64 * 1024
+)
+
+type checkpointStore interface {
+	GetCheckpoint(name string) ([]byte, error)
+	SaveCheckpoint(name string, value []byte) error
+}
+
+// FileSource is a source.Source implementation that follows an audit log file written by the kubernetes API server log
+// backend (--audit-log-path)
+type FileSource struct {
+	path   string
+	store  checkpointStore
+	filter *auditfilter.Filter
+}
+
+// NewFileSource instantiates and returns FileSource. The byte offset reached within the file, and a fingerprint
+// identifying the file, are persisted to the supplied store, so that following can resume where it left off. Events
+// not matched by the filter are discarded.
+func NewFileSource(path string, store checkpointStore, filter *auditfilter.Filter) *FileSource {
+	return &FileSource{
+		path:   path,
+		store:  store,
+		filter: filter,
+	}
+}
+
+// Run follows the audit log file until the context is cancelled. Only audit events relating to fluxcd resources, and
+// matched by the filter, are passed to the handler. Rotation (rename and recreate) and truncation of the file are both
+// handled.
+func (s *FileSource) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	t := &fileTailer{
+		path:       s.path,
+		checkpoint: fmt.Sprintf("kubeaudit:file:%s", s.path),
+		store:      s.store,
+		filter:     s.filter,
+		handle: func(event source.Event) error {
+			return handle(ctx, event)
+		},
+	}
+	defer t.close()
+
+	cp, err := t.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	t.offset = cp.Offset
+	t.fingerprint = cp.Fingerprint
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err = t.poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type fileTailer struct {
+	path       string
+	checkpoint string
+	store      checkpointStore
+	filter     *auditfilter.Filter
+	handle     func(source.Event) error
+	file       *os.File
+	reader     *bufio.Reader
+	offset     int64
+	// fingerprint identifies the file the offset relates to, or is empty if not yet known
+	fingerprint string
+}
+
+// fileCheckpoint records the position reached within the audit log file
+type fileCheckpoint struct {
+	Offset int64 `json:"offset"`
+	// Fingerprint is a hash of the first line of the file, so that a file rotated while the source was not running is
+	// not resumed at an offset recorded against its predecessor
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// poll reads any complete lines appended since the last poll, and then checks whether the file has been rotated or
+// truncated
+func (t *fileTailer) poll() error {
+	if t.file == nil {
+		opened, err := t.open()
+		if err != nil || !opened {
+			return err
+		}
+	}
+
+	if err := t.readLines(); err != nil {
+		return err
+	}
+
+	pathInfo, err := os.Stat(t.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // Rotated, but not yet recreated
+		}
+		return fmt.Errorf("failed to stat audit log file: %w", err)
+	}
+	fileInfo, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat open audit log file: %w", err)
+	}
+
+	switch {
+	case !os.SameFile(pathInfo, fileInfo):
+		slog.Info("audit log file rotated", slog.String("path", t.path))
+		// Drain anything written to the old file between the last read and the rotation
+		if err = t.readLines(); err != nil {
+			return err
+		}
+		t.close()
+		t.offset = 0
+		t.fingerprint = ""
+		return t.saveCheckpoint()
+	case pathInfo.Size() < t.offset:
+		slog.Info("audit log file truncated", slog.String("path", t.path))
+		if err = t.seek(0); err != nil {
+			return err
+		}
+		t.fingerprint = ""
+		return t.saveCheckpoint()
+	}
+	return nil
+}
+
+// open opens the audit log file, positioning it at the current offset. If the file is not the one the offset was
+// recorded against, it is read from the start. False is returned if the file does not exist.
+func (t *fileTailer) open() (bool, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("audit log file does not exist", slog.String("path", t.path))
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	t.file = f
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat audit log file: %w", err)
+	}
+
+	fingerprint, err := fingerprintFile(f)
+	if err != nil {
+		return false, err
+	}
+
+	offset := t.offset
+	switch {
+	case t.fingerprint != "" && fingerprint != t.fingerprint:
+		slog.Info("audit log file replaced since checkpoint, reading from start", slog.String("path", t.path))
+		offset = 0
+	case info.Size() < offset:
+		// The file has been truncated or replaced since the offset was recorded
+		offset = 0
+	}
+	t.fingerprint = fingerprint
+	if err = t.seek(offset); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// readLines consumes all complete lines from the current offset. An incomplete trailing line is left to be read once
+// the writer has finished it.
+func (t *fileTailer) readLines() error {
+	startOffset := t.offset
+	for {
+		line, err := t.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				if err = t.seek(t.offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log file: %w", err)
+		}
+
+		processed, err := t.handleLine(line)
+		if err != nil {
+			return err
+		}
+		t.offset += int64(len(line))
+
+		if processed {
+			// Persist immediately after an event has been handled, so it isn't handled twice
+			if err = t.saveCheckpoint(); err != nil {
+				return err
+			}
+			startOffset = t.offset
+		}
+	}
+
+	if t.offset != startOffset {
+		return t.saveCheckpoint()
+	}
+	return nil
+}
+
+// handleLine decodes a single audit event, and passes it to the handler if relevant. True is returned if the handler
+// was invoked.
+func (t *fileTailer) handleLine(line []byte) (bool, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return false, nil
+	}
+
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		slog.Warn("failed to unmarshal audit event", slog.Any("error", err))
+		return false, nil
+	}
+	if !event.IsFluxMutation(t.filter) {
+		return false, nil
+	}
+
+	if err := t.handle(event.SourceEvent()); err != nil {
+		return false, fmt.Errorf("handler failed: %w", err)
+	}
+	return true, nil
+}
+
+func (t *fileTailer) seek(offset int64) error {
+	if _, err := t.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek audit log file: %w", err)
+	}
+	t.offset = offset
+	if t.reader == nil {
+		t.reader = bufio.NewReader(t.file)
+	} else {
+		t.reader.Reset(t.file)
+	}
+	return nil
+}
+
+func (t *fileTailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+func (t *fileTailer) loadCheckpoint() (fileCheckpoint, error) {
+	value, err := t.store.GetCheckpoint(t.checkpoint)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return fileCheckpoint{}, nil
+		}
+		return fileCheckpoint{}, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	// Checkpoints saved before fingerprints were recorded hold a bare offset
+	if offset, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		return fileCheckpoint{Offset: offset}, nil
+	}
+	var cp fileCheckpoint
+	if err = json.Unmarshal(value, &cp); err != nil {
+		return fileCheckpoint{}, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func (t *fileTailer) saveCheckpoint() error {
+	// The first line of a file is only complete once the offset has moved beyond it
+	if t.fingerprint == "" && t.offset > 0 && t.file != nil {
+		fingerprint, err := fingerprintFile(t.file)
+		if err != nil {
+			return err
+		}
+		t.fingerprint = fingerprint
+	}
+	value, err := json.Marshal(fileCheckpoint{
+		Offset:      t.offset,
+		Fingerprint: t.fingerprint,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err = t.store.SaveCheckpoint(t.checkpoint, value); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// fingerprintFile returns a hash of the first line of the file, which identifies it as each audit event is unique. An
+// empty string is returned if the first line is not yet complete.
+func fingerprintFile(f *os.File) (string, error) {
+	buf := make([]byte, fingerprintMaxSize)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read audit log file: %w", err)
+	}
+	line, _, complete := bytes.Cut(buf[:n], []byte("\n"))
+	if !complete && n < fingerprintMaxSize {
+		return "", nil
+	}
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:]), nil
+}
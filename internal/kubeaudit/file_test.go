@@ -0,0 +1,217 @@
+package kubeaudit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+func TestFileTailer_partialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	b := auditLine("b")
+	writeFile(t, path, auditLine("a")+b[:20])
+
+	store := newFakeCheckpointStore()
+	tailer, handled := newTestTailer(t, path, store)
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a")
+
+	appendFile(t, path, b[20:])
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a", "b")
+
+	if cp := loadTestCheckpoint(t, path, store); cp.Offset != int64(len(auditLine("a"))+len(b)) || cp.Fingerprint == "" {
+		t.Errorf("unexpected checkpoint: %+v", cp)
+	}
+}
+
+func TestFileTailer_rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeFile(t, path, auditLine("a"))
+
+	tailer, handled := newTestTailer(t, path, newFakeCheckpointStore())
+	tailer.mustPoll(t)
+
+	// Written to the old file before it is rotated, and so must be drained
+	appendFile(t, path, auditLine("b"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a", "b")
+
+	writeFile(t, path, auditLine("c"))
+	tailer.mustPoll(t) // Detects the rotation
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a", "b", "c")
+}
+
+func TestFileTailer_truncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeFile(t, path, auditLine("a")+auditLine("b"))
+
+	tailer, handled := newTestTailer(t, path, newFakeCheckpointStore())
+	tailer.mustPoll(t)
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, auditLine("c"))
+	tailer.mustPoll(t) // Detects the truncation
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a", "b", "c")
+}
+
+func TestFileTailer_resumesFromLegacyCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeFile(t, path, auditLine("a")+auditLine("b"))
+
+	store := newFakeCheckpointStore()
+	offset := strconv.Itoa(len(auditLine("a")))
+	if err := store.SaveCheckpoint(fileCheckpointName(path), []byte(offset)); err != nil {
+		t.Fatal(err)
+	}
+
+	tailer, handled := newTestTailer(t, path, store)
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "b")
+}
+
+func TestFileTailer_fileReplacedWhileStopped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeFile(t, path, auditLine("a")+auditLine("b"))
+
+	store := newFakeCheckpointStore()
+	tailer, handled := newTestTailer(t, path, store)
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "a", "b")
+	tailer.close()
+
+	// Larger than the offset reached, so only identified as a different file by its fingerprint
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, auditLine("c")+auditLine("d")+auditLine("e"))
+
+	tailer, handled = newTestTailer(t, path, store)
+	tailer.mustPoll(t)
+	assertHandled(t, *handled, "c", "d", "e")
+}
+
+// newTestTailer returns a tailer resuming from the checkpoint in the store, as FileSource.Run does, along with the
+// requests of the events handled, which identify the lines read
+func newTestTailer(t *testing.T, path string, store checkpointStore) (*fileTailer, *[]string) {
+	t.Helper()
+
+	var handled []string
+	tailer := &fileTailer{
+		path:       path,
+		checkpoint: fileCheckpointName(path),
+		store:      store,
+		filter:     auditfilter.Default(),
+		handle: func(event source.Event) error {
+			handled = append(handled, string(event.Request))
+			return nil
+		},
+	}
+	t.Cleanup(tailer.close)
+
+	cp, err := tailer.loadCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailer.offset = cp.Offset
+	tailer.fingerprint = cp.Fingerprint
+	return tailer, &handled
+}
+
+func (t *fileTailer) mustPoll(tb testing.TB) {
+	tb.Helper()
+	if err := t.poll(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func fileCheckpointName(path string) string {
+	return fmt.Sprintf("kubeaudit:file:%s", path)
+}
+
+func loadTestCheckpoint(t *testing.T, path string, store checkpointStore) fileCheckpoint {
+	t.Helper()
+	tailer := &fileTailer{checkpoint: fileCheckpointName(path), store: store}
+	cp, err := tailer.loadCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
+
+func assertHandled(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	wantRequests := make([]string, 0, len(want))
+	for _, id := range want {
+		wantRequests = append(wantRequests, fmt.Sprintf(`{"metadata":{"labels":{"id":%q}}}`, id))
+	}
+	if !slices.Equal(got, wantRequests) {
+		t.Errorf("handled %q, want %q", got, wantRequests)
+	}
+}
+
+// auditLine returns a line of the audit log recording a patch to a fluxcd resource, whose request identifies the line
+func auditLine(id string) string {
+	return fmt.Sprintf(
+		`{"auditID":%q,"stage":"ResponseComplete","verb":"patch","user":{"username":"alice@acme.com"},`+
+			`"objectRef":{"resource":"kustomizations","namespace":"flux-system","name":"podinfo",`+
+			`"apiGroup":"kustomize.toolkit.fluxcd.io","apiVersion":"v1"},"responseStatus":{"code":200},`+
+			`"requestObject":{"metadata":{"labels":{"id":%q}}},"stageTimestamp":"2024-07-01T12:00:00Z"}`+"\n",
+		id,
+		id,
+	)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type fakeCheckpointStore struct {
+	checkpoints map[string][]byte
+}
+
+func newFakeCheckpointStore() *fakeCheckpointStore {
+	return &fakeCheckpointStore{checkpoints: make(map[string][]byte)}
+}
+
+func (s *fakeCheckpointStore) GetCheckpoint(name string) ([]byte, error) {
+	value, ok := s.checkpoints[name]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fakeCheckpointStore) SaveCheckpoint(name string, value []byte) error {
+	s.checkpoints[name] = value
+	return nil
+}
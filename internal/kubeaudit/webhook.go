@@ -0,0 +1,180 @@
+package kubeaudit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+// maxEventListSize bounds the size of an audit event batch. The API server batches at most 400 events by default, so
+// this leaves ample room for events recording request and response bodies.
+const maxEventListSize = 32 << 20
+
+// WebhookConfig identifies where the receiver listens, and how the API server is authenticated. These should match the
+// kubeconfig supplied to the API server via --audit-webhook-config-file.
+type WebhookConfig struct {
+	ListenAddress string
+	Path          string
+	// CertFile and KeyFile enable TLS, serving the certificate trusted by the certificate-authority of the kubeconfig
+	CertFile string
+	KeyFile  string
+	// ClientCAFile requires the API server to present a certificate signed by one of the CAs in the file, i.e. the
+	// client-certificate of the kubeconfig. Requires TLS.
+	ClientCAFile string
+}
+
+// Validate checks that the configuration is usable
+func (c WebhookConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("both cert file and key file must be supplied")
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return errors.New("client ca file requires cert file and key file")
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the server, or nil if TLS is not enabled
+func (c WebhookConfig) tlsConfig() (*tls.Config, error) {
+	if c.CertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in client ca file")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// WebhookSource is a source.Source implementation that runs an HTTP server, which receives audit event batches from
+// the kubernetes API server audit webhook backend
+type WebhookSource struct {
+	config WebhookConfig
+	filter *auditfilter.Filter
+}
+
+// NewWebhookSource instantiates and returns WebhookSource. The server will listen on the configured address, and accept
+// audit event batches at the configured path. Events not matched by the filter are discarded.
+func NewWebhookSource(config WebhookConfig, filter *auditfilter.Filter) *WebhookSource {
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	return &WebhookSource{
+		config: config,
+		filter: filter,
+	}
+}
+
+// Run serves the audit webhook until the context is cancelled. Only audit events relating to fluxcd resources, and
+// matched by the filter, are passed to the handler.
+func (s *WebhookSource) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	tlsConfig, err := s.config.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(s.config.Path, &webhookHandler{handle: handle, filter: s.filter})
+
+	server := &http.Server{
+		Addr:              s.config.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+		TLSConfig:         tlsConfig,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info(
+			"audit webhook listening",
+			slog.String("addr", s.config.ListenAddress),
+			slog.String("path", s.config.Path),
+			slog.Bool("tls", tlsConfig != nil),
+			slog.Bool("clientAuth", s.config.ClientCAFile != ""),
+		)
+		if tlsConfig != nil {
+			errCh <- server.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+			return
+		}
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shutdown server: %w", err)
+		}
+		return ctx.Err()
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("server failed: %w", err)
+	}
+}
+
+type webhookHandler struct {
+	// mu serialises handler invocations, as the API server may deliver batches concurrently
+	mu     sync.Mutex
+	handle source.Handler
+	filter *auditfilter.Filter
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var eventList EventList
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventListSize)).Decode(&eventList); err != nil {
+		slog.Warn("failed to decode audit event list", slog.Any("error", err))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "event list too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "malformed event list", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, event := range eventList.Items {
+		if !event.IsFluxMutation(h.filter) {
+			continue
+		}
+		if err := h.handle(r.Context(), event.SourceEvent()); err != nil {
+			// The API server will retry delivery of the batch, based on its webhook backoff configuration
+			slog.Error("handler failed", slog.Any("error", err), slog.String("auditID", event.AuditID))
+			http.Error(w, "failed to process event", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package kubeaudit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  WebhookConfig
+		wantErr bool
+	}{
+		{
+			name:   "plaintext",
+			config: WebhookConfig{ListenAddress: ":8080"},
+		},
+		{
+			name:   "tls",
+			config: WebhookConfig{ListenAddress: ":8443", CertFile: "tls.crt", KeyFile: "tls.key"},
+		},
+		{
+			name:   "client certificates",
+			config: WebhookConfig{ListenAddress: ":8443", CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt"},
+		},
+		{
+			name:    "cert without key",
+			config:  WebhookConfig{ListenAddress: ":8443", CertFile: "tls.crt"},
+			wantErr: true,
+		},
+		{
+			name:    "client certificates without tls",
+			config:  WebhookConfig{ListenAddress: ":8080", ClientCAFile: "ca.crt"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_ServeHTTP(t *testing.T) {
+	event := `{
+		"auditID": "a1",
+		"stage": "ResponseComplete",
+		"verb": "patch",
+		"user": {"username": "alice@acme.com"},
+		"objectRef": {"resource": "kustomizations", "namespace": "flux-system", "name": "podinfo", "apiGroup": "kustomize.toolkit.fluxcd.io", "apiVersion": "v1"},
+		"responseStatus": {"code": 200},
+		"requestObject": {"spec": {"suspend": true}},
+		"stageTimestamp": "2024-07-01T12:00:00Z"
+	}`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantEvents int
+	}{
+		{
+			name:       "event list",
+			body:       `{"items":[` + event + `]}`,
+			wantStatus: http.StatusOK,
+			wantEvents: 1,
+		},
+		{
+			name:       "malformed",
+			body:       `{"items":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			body:       `{"items":[],"padding":"` + strings.Repeat("x", maxEventListSize) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []source.Event
+			h := &webhookHandler{
+				filter: auditfilter.Default(),
+				handle: func(_ context.Context, event source.Event) error {
+					events = append(events, event)
+					return nil
+				},
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(events) != tt.wantEvents {
+				t.Errorf("handled %d events, want %d", len(events), tt.wantEvents)
+			}
+		})
+	}
+}
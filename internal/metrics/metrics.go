@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fluxcd_suspend_notifier"
+
+// DuplicateAuditEntries counts audit log entries dropped because they had already been handled, e.g. when redelivered
+// after a stream restart
+var DuplicateAuditEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "duplicate_audit_entries_total",
+	Help:      "Audit log entries dropped because they had already been handled.",
+}, []string{"cluster"})
+
+// EventRetries counts failures to handle an event that are expected to be transient, and so are retried
+var EventRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "event_retries_total",
+	Help:      "Failures to handle an event that were queued to be retried.",
+}, []string{"cluster"})
+
+// EventFailures counts events that could not be handled, either due to a permanent error or because retries were
+// exhausted
+var EventFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "event_failures_total",
+	Help:      "Events that could not be handled, and were recorded as failed.",
+}, []string{"cluster"})
+
+// ReconciliationDrift counts differences between the cluster and the stored state found by periodic reconciliation,
+// i.e. suspension changes or deletions that were not observed via the source. Differences found at startup are not
+// counted.
+var ReconciliationDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "reconciliation_drift_total",
+	Help:      "Suspension changes and deletions detected by periodic reconciliation, rather than observed via the source.",
+}, []string{"cluster"})
+
+// Serve exposes metrics in the Prometheus exposition format at /metrics, until the context is cancelled
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("metrics listening", slog.String("addr", addr))
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shutdown metrics server: %w", err)
+		}
+		return ctx.Err()
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+}
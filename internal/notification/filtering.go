@@ -29,9 +29,20 @@ type FilteringNotifier struct {
 // Notify passes the notification to the underlying delegate if the expression is satisfied.
 func (fn *FilteringNotifier) Notify(ctx context.Context, notif Notification) error {
 	env := map[string]interface{}{
-		"resource":  notif.Resource,
-		"suspended": notif.Suspended,
-		"email":     notif.Email,
+		"cluster":    notif.Cluster,
+		"resource":   notif.Resource,
+		"suspended":  notif.Suspended,
+		"deleted":    notif.Deleted,
+		"reconciled": notif.Reconciled,
+		"time":       notif.Time,
+		"email":      notif.Email,
+		"actor":      notif.Actor,
+		"onBehalfOf": notif.Actor.OnBehalfOf(),
+		"chain":      notif.Actor.Chain(),
+		"callerIP":   notif.Actor.CallerIP,
+		"userAgent":  notif.Actor.UserAgent,
+		"client":     notif.Actor.Client(),
+		"actorKind":  string(notif.Actor.Kind()),
 	}
 
 	output, err := expr.Run(fn.filter, env)
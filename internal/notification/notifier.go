@@ -2,16 +2,51 @@ package notification
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
 )
 
 // Notification carries information relevant for dispatching external notifications
 type Notification struct {
+	Cluster              string
 	Resource             k8s.ResourceReference
 	Suspended            bool
 	Email                string
 	GoogleCloudProjectID string
+	// Actor holds the full attribution of the change; Email is the authenticated principal
+	Actor actor.Actor
+	// Time is when the change was made, as far as is known
+	Time time.Time
+	// Deleted is true if the resource was deleted, in which case Suspended is the status it held when deleted
+	Deleted bool
+	// Reconciled is true if the change was detected by reconciliation against the cluster, rather than observed via
+	// an audit event, in which case the actor is unknown
+	Reconciled bool
+}
+
+// Action describes what happened to the resource
+func (n Notification) Action() string {
+	switch {
+	case n.Deleted && n.Suspended:
+		return "deleted while suspended"
+	case n.Deleted:
+		return "deleted while active"
+	case n.Suspended:
+		return "suspended"
+	default:
+		return "resumed"
+	}
+}
+
+// Summary describes what happened to the resource, and who did it
+func (n Notification) Summary() string {
+	if n.Reconciled {
+		return fmt.Sprintf("%s (detected by reconciliation)", n.Action())
+	}
+	return fmt.Sprintf("%s by %s", n.Action(), n.Actor)
 }
 
 // Notifier is the interface that is expected to be implemented for notification mechanisms
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
 )
 
 // SlackNotifier sends notifications to Slack via a webhook
@@ -42,6 +44,8 @@ type SlackAttachment struct {
 	Text       string                 `json:"text"`
 	MrkdwnIn   []string               `json:"mrkdwn_in"`
 	Fields     []SlackAttachmentField `json:"fields,omitempty"`
+	// Ts is the time the change was made, as a unix timestamp, which Slack displays in the attachment footer
+	Ts int64 `json:"ts,omitempty"`
 }
 
 // SlackAttachmentField forms part of a Slack webhook attachment value
@@ -53,33 +57,67 @@ type SlackAttachmentField struct {
 
 // Notify sends a notification via the underlying Slack webhook URL.
 func (sn *SlackNotifier) Notify(ctx context.Context, notif Notification) error {
-	var (
-		action string
-		color  string
-	)
-	if notif.Suspended {
-		action = "suspended"
+	var color string
+	switch {
+	case notif.Deleted:
+		color = "warning"
+	case notif.Suspended:
 		color = "danger"
-	} else {
-		action = "resumed"
+	default:
 		color = "good"
 	}
 
 	kind := strings.TrimSuffix(notif.Resource.Type.Kind, "s")
 
+	var fields []SlackAttachmentField
+	if notif.GoogleCloudProjectID != "" {
+		fields = append(fields, SlackAttachmentField{
+			Title: "project",
+			Value: notif.GoogleCloudProjectID,
+		})
+	}
+	if notif.Cluster != "" {
+		fields = append(fields, SlackAttachmentField{
+			Title: "cluster",
+			Value: notif.Cluster,
+		})
+	}
+	if kind := notif.Actor.Kind(); kind != actor.KindUnknown {
+		fields = append(fields, SlackAttachmentField{
+			Title: "actor",
+			Value: string(kind),
+			Short: true,
+		})
+	}
+	if client := notif.Actor.Client(); client != "" {
+		fields = append(fields, SlackAttachmentField{
+			Title: "client",
+			Value: client,
+			Short: true,
+		})
+	}
+	if notif.Actor.CallerIP != "" {
+		fields = append(fields, SlackAttachmentField{
+			Title: "caller ip",
+			Value: notif.Actor.CallerIP,
+			Short: true,
+		})
+	}
+
+	var ts int64
+	if !notif.Time.IsZero() {
+		ts = notif.Time.Unix()
+	}
+
 	reqBody, err := json.Marshal(SlackWebhook{
 		Attachments: []SlackAttachment{
 			{
 				Color:      color,
 				AuthorName: fmt.Sprintf("%s/%s.%s", kind, notif.Resource.Name, notif.Resource.Namespace),
-				Text:       fmt.Sprintf("%s by %s", action, notif.Email),
+				Text:       notif.Summary(),
 				MrkdwnIn:   []string{"text"},
-				Fields: []SlackAttachmentField{
-					{
-						Title: "project",
-						Value: notif.GoogleCloudProjectID,
-					},
-				},
+				Fields:     fields,
+				Ts:         ts,
 			},
 		},
 	})
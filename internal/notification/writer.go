@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriterNotifier writes notifications as human-readable lines, for example to stdout
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterNotifier instantiates and returns WriterNotifier
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{
+		w: w,
+	}
+}
+
+// Notify writes the notification as a single line
+func (wn *WriterNotifier) Notify(_ context.Context, notif Notification) error {
+	kind := strings.TrimSuffix(notif.Resource.Type.Kind, "s")
+
+	wn.mu.Lock()
+	defer wn.mu.Unlock()
+
+	_, err := fmt.Fprintf(
+		wn.w,
+		"%s\t%s\t%s/%s.%s\t%s\n",
+		notif.Time.UTC().Format(time.RFC3339),
+		notif.Cluster,
+		kind,
+		notif.Resource.Name,
+		notif.Resource.Namespace,
+		notif.Summary(),
+	)
+	return err
+}
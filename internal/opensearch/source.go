@@ -0,0 +1,423 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kubeaudit"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+const (
+	defaultPollInterval    = time.Second * 30
+	defaultPageSize        = 500
+	defaultTimestampField  = "stageTimestamp"
+	defaultTiebreakerField = "auditID.keyword"
+	// lookback is how far before the cursor each poll starts. Documents are not guaranteed to be indexed in timestamp
+	// order (e.g. when buffered by Fluent Bit or an ingest pipeline), so polling overlaps to pick up late arrivals;
+	// events already handled are dropped.
+	lookback = time.Minute * 5
+	// seenTTL is how long handled events are remembered for, which must exceed lookback
+	seenTTL = time.Hour
+)
+
+type store interface {
+	GetCheckpoint(name string) ([]byte, error)
+	SaveCheckpoint(name string, value []byte) error
+	HasSeen(key string) (bool, error)
+	MarkSeen(key string, ttl time.Duration) error
+}
+
+// Config identifies the cluster, indices and query used to search for audit events. The optional query is combined
+// with the verb filter, and is typically used to select the cluster where several are indexed together.
+type Config struct {
+	URL      string
+	Username string
+	Password string
+	// InsecureSkipVerify disables TLS certificate verification, e.g. for a local single-node cluster
+	InsecureSkipVerify bool
+	// Index is the index name or pattern searched, e.g. audit-*
+	Index string
+	// Query is an optional query DSL clause (JSON) that hits must also match
+	Query string
+	// EventField is the dot separated path to the audit event within each document. By default, the document is the
+	// audit event.
+	EventField string
+	// TimestampField and TiebreakerField are used to sort hits; together they must uniquely order hits. They default
+	// to the stageTimestamp and auditID.keyword fields of the audit event.
+	TimestampField  string
+	TiebreakerField string
+	PageSize        int
+	PollInterval    time.Duration
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	if _, err := url.ParseRequestURI(c.URL); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if c.Index == "" {
+		return errors.New("index must be supplied")
+	}
+	if c.Query != "" && !json.Valid([]byte(c.Query)) {
+		return errors.New("query is not valid json")
+	}
+	return nil
+}
+
+// eventField returns the path to a field of the audit event within each document
+func (c Config) eventField(name string) string {
+	if c.EventField == "" {
+		return name
+	}
+	return c.EventField + "." + name
+}
+
+// Source is a source.Source implementation that periodically searches OpenSearch (or Elasticsearch) for kubernetes
+// audit events. Each poll searches from shortly before the sort values of the last hit handled, which are persisted
+// in the store, and pages through hits with search_after.
+type Source struct {
+	config Config
+	store  store
+	filter *auditfilter.Filter
+}
+
+// NewSource instantiates and returns Source. The cursor, and the events recently handled, are persisted to the
+// supplied store. Events not matched by the filter are discarded; the verbs are also used to narrow the search
+// server-side.
+func NewSource(config Config, store store, filter *auditfilter.Filter) *Source {
+	if config.TimestampField == "" {
+		config.TimestampField = config.eventField(defaultTimestampField)
+	}
+	if config.TiebreakerField == "" {
+		config.TiebreakerField = config.eventField(defaultTiebreakerField)
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = defaultPageSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	return &Source{
+		config: config,
+		store:  store,
+		filter: filter,
+	}
+}
+
+// Run searches for audit events, passing successful operations against fluxcd resources to the handler. If no cursor
+// has been persisted, searching starts from the current time.
+func (s *Source) Run(ctx context.Context, _ []k8s.ResourceType, handle source.Handler) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if s.config.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	sr := &searcher{
+		client: &http.Client{
+			Timeout:   time.Second * 30,
+			Transport: transport,
+		},
+		config:     s.config,
+		checkpoint: fmt.Sprintf("opensearch:%s", s.config.Index),
+		store:      s.store,
+		filter:     s.filter,
+		handle:     handle,
+		startedAt:  time.Now().UTC(),
+	}
+
+	cursor, err := sr.loadCursor()
+	if err != nil {
+		return err
+	}
+	sr.cursor = cursor
+
+	slog.Info("searching for audit events", slog.String("index", s.config.Index))
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err = sr.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type searcher struct {
+	client     *http.Client
+	config     Config
+	checkpoint string
+	store      store
+	filter     *auditfilter.Filter
+	handle     source.Handler
+	// startedAt bounds the first search, until a cursor exists
+	startedAt time.Time
+	// cursor holds the sort values of the most recent hit handled
+	cursor []json.RawMessage
+	// after holds the sort values of the last hit of the previous page, while paging through a poll
+	after []json.RawMessage
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string            `json:"_id"`
+			Source json.RawMessage   `json:"_source"`
+			Sort   []json.RawMessage `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// poll pages through all hits since shortly before the cursor. Failed searches are logged, rather than returned, as
+// they will be retried by the next poll.
+func (sr *searcher) poll(ctx context.Context) error {
+	sr.after = nil
+	if _, ok := sr.cursorTime(); !ok {
+		// The timestamp of the cursor is not known, so the overlap cannot be applied
+		sr.after = sr.cursor
+	}
+
+	var count int
+	for {
+		resp, err := sr.search(ctx)
+		if err != nil {
+			slog.Warn("search failed, will retry", slog.Any("error", err))
+			return nil
+		}
+
+		for _, hit := range resp.Hits.Hits {
+			handled, err := sr.handleHit(ctx, hit.ID, hit.Source)
+			if err != nil {
+				return err
+			}
+			if handled {
+				count++
+			}
+			sr.after = hit.Sort
+			if sr.isAfterCursor(hit.Sort) {
+				sr.cursor = hit.Sort
+			}
+		}
+		if len(resp.Hits.Hits) > 0 {
+			if err = sr.saveCursor(); err != nil {
+				return err
+			}
+		}
+
+		if len(resp.Hits.Hits) < sr.config.PageSize {
+			break
+		}
+	}
+
+	slog.Debug("searched for audit events", slog.Int("events", count))
+	return nil
+}
+
+// search requests the next page of hits after the cursor
+func (sr *searcher) search(ctx context.Context) (searchResponse, error) {
+	body, err := json.Marshal(sr.buildRequest())
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/_search", strings.TrimSuffix(sr.config.URL, "/"), url.PathEscape(sr.config.Index))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sr.config.Username != "" {
+		req.SetBasicAuth(sr.config.Username, sr.config.Password)
+	}
+
+	resp, err := sr.client.Do(req)
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("send request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return searchResponse{}, fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, msg)
+	}
+
+	var searchResp searchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return searchResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return searchResp, nil
+}
+
+// buildRequest builds the search request body. Completed operations using a watched verb are selected server-side;
+// the remaining filtering is applied to each hit.
+func (sr *searcher) buildRequest() map[string]any {
+	filters := []any{
+		map[string]any{"terms": map[string]any{sr.config.eventField("verb"): sr.filter.Verbs()}},
+		map[string]any{"match": map[string]any{sr.config.eventField("stage"): kubeaudit.StageResponseComplete}},
+	}
+	if sr.config.Query != "" {
+		filters = append(filters, json.RawMessage(sr.config.Query))
+	}
+	from, ok := sr.cursorTime()
+	switch {
+	case ok:
+		from = from.Add(-lookback)
+	case sr.cursor == nil:
+		from = sr.startedAt
+	}
+	if !from.IsZero() {
+		filters = append(filters, map[string]any{
+			"range": map[string]any{
+				sr.config.TimestampField: map[string]any{"gte": from.Format(time.RFC3339Nano)},
+			},
+		})
+	}
+
+	req := map[string]any{
+		"size":  sr.config.PageSize,
+		"query": map[string]any{"bool": map[string]any{"filter": filters}},
+		"sort": []any{
+			map[string]any{sr.config.TimestampField: "asc"},
+			map[string]any{sr.config.TiebreakerField: "asc"},
+		},
+	}
+	if sr.after != nil {
+		req["search_after"] = sr.after
+	}
+	return req
+}
+
+// cursorTime returns the timestamp of the cursor. Date fields are sorted by their value in epoch milliseconds. False is
+// returned if there is no cursor, or its timestamp is not a number.
+func (sr *searcher) cursorTime() (time.Time, bool) {
+	return sortTime(sr.cursor)
+}
+
+// isAfterCursor returns true if the sort values of a hit are after the cursor, i.e. if the cursor should be advanced
+// to it. Hits are only compared by timestamp, as hits within the overlap are older than the cursor.
+func (sr *searcher) isAfterCursor(sort []json.RawMessage) bool {
+	cursorTime, ok := sr.cursorTime()
+	if !ok {
+		return true
+	}
+	hitTime, ok := sortTime(sort)
+	return ok && !hitTime.Before(cursorTime)
+}
+
+// sortTime returns the timestamp held by the first of the supplied sort values, as epoch milliseconds
+func sortTime(sort []json.RawMessage) (time.Time, bool) {
+	if len(sort) == 0 {
+		return time.Time{}, false
+	}
+	millis, err := strconv.ParseInt(string(sort[0]), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis).UTC(), true
+}
+
+// handleHit decodes the audit event held by a hit, and passes it to the handler if relevant and not already handled.
+// True is returned if the handler was invoked.
+func (sr *searcher) handleHit(ctx context.Context, id string, document json.RawMessage) (bool, error) {
+	raw, err := extractField(document, sr.config.EventField)
+	if err != nil {
+		slog.Warn("failed to extract audit event", slog.Any("error", err), slog.String("id", id))
+		return false, nil
+	}
+
+	var event kubeaudit.Event
+	if err = json.Unmarshal(raw, &event); err != nil {
+		slog.Warn("failed to unmarshal audit event", slog.Any("error", err), slog.String("id", id))
+		return false, nil
+	}
+	if !event.IsFluxMutation(sr.filter) {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("opensearch:%s", event.AuditID)
+	if event.AuditID == "" {
+		key = fmt.Sprintf("opensearch:%s:%s", sr.config.Index, id)
+	}
+	seen, err := sr.store.HasSeen(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate: %w", err)
+	}
+	if seen {
+		return false, nil
+	}
+
+	if err = sr.handle(ctx, event.SourceEvent()); err != nil {
+		return false, fmt.Errorf("handler failed: %w", err)
+	}
+
+	if err = sr.store.MarkSeen(key, seenTTL); err != nil {
+		return false, fmt.Errorf("failed to mark event as seen: %w", err)
+	}
+	return true, nil
+}
+
+// extractField returns the value at the dot separated path within the document. The document itself is returned if
+// the path is empty.
+func extractField(document json.RawMessage, path string) (json.RawMessage, error) {
+	if path == "" {
+		return document, nil
+	}
+	value := document
+	for _, key := range strings.Split(path, ".") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+		}
+		var ok bool
+		if value, ok = fields[key]; !ok {
+			return nil, fmt.Errorf("field %s not found", key)
+		}
+	}
+	return value, nil
+}
+
+func (sr *searcher) loadCursor() ([]json.RawMessage, error) {
+	value, err := sr.store.GetCheckpoint(sr.checkpoint)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	var cursor []json.RawMessage
+	if err = json.Unmarshal(value, &cursor); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	return cursor, nil
+}
+
+func (sr *searcher) saveCursor() error {
+	value, err := json.Marshal(sr.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err = sr.store.SaveCheckpoint(sr.checkpoint, value); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+func TestSearcher_buildRequest(t *testing.T) {
+	startedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	cursorAt := startedAt.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		config Config
+		cursor string
+		after  string
+		want   string
+	}{
+		{
+			name:   "no cursor",
+			config: NewSource(Config{Index: "audit-*", PageSize: 10}, nil, nil).config,
+			want: `{
+				"size": 10,
+				"query": {"bool": {"filter": [
+					{"terms": {"verb": ["patch", "create", "delete"]}},
+					{"match": {"stage": "ResponseComplete"}},
+					{"range": {"stageTimestamp": {"gte": "2024-07-01T12:00:00Z"}}}
+				]}},
+				"sort": [{"stageTimestamp": "asc"}, {"auditID.keyword": "asc"}]
+			}`,
+		},
+		{
+			name:   "cursor",
+			config: NewSource(Config{Index: "audit-*", PageSize: 10}, nil, nil).config,
+			cursor: fmt.Sprintf(`[%d, "a1"]`, cursorAt.UnixMilli()),
+			want: `{
+				"size": 10,
+				"query": {"bool": {"filter": [
+					{"terms": {"verb": ["patch", "create", "delete"]}},
+					{"match": {"stage": "ResponseComplete"}},
+					{"range": {"stageTimestamp": {"gte": "2024-07-01T12:55:00Z"}}}
+				]}},
+				"sort": [{"stageTimestamp": "asc"}, {"auditID.keyword": "asc"}]
+			}`,
+		},
+		{
+			name:   "paging",
+			config: NewSource(Config{Index: "audit-*", PageSize: 10}, nil, nil).config,
+			cursor: fmt.Sprintf(`[%d, "a1"]`, cursorAt.UnixMilli()),
+			after:  fmt.Sprintf(`[%d, "a0"]`, cursorAt.Add(-time.Minute).UnixMilli()),
+			want: `{
+				"size": 10,
+				"query": {"bool": {"filter": [
+					{"terms": {"verb": ["patch", "create", "delete"]}},
+					{"match": {"stage": "ResponseComplete"}},
+					{"range": {"stageTimestamp": {"gte": "2024-07-01T12:55:00Z"}}}
+				]}},
+				"sort": [{"stageTimestamp": "asc"}, {"auditID.keyword": "asc"}],
+				"search_after": [1719838740000, "a0"]
+			}`,
+		},
+		{
+			name:   "cursor without timestamp",
+			config: NewSource(Config{Index: "audit-*", PageSize: 10}, nil, nil).config,
+			cursor: `["2024-07-01T13:00:00Z", "a1"]`,
+			after:  `["2024-07-01T13:00:00Z", "a1"]`,
+			want: `{
+				"size": 10,
+				"query": {"bool": {"filter": [
+					{"terms": {"verb": ["patch", "create", "delete"]}},
+					{"match": {"stage": "ResponseComplete"}}
+				]}},
+				"sort": [{"stageTimestamp": "asc"}, {"auditID.keyword": "asc"}],
+				"search_after": ["2024-07-01T13:00:00Z", "a1"]
+			}`,
+		},
+		{
+			name: "nested event and query",
+			config: NewSource(Config{
+				Index:      "logs-*",
+				PageSize:   10,
+				EventField: "kubernetes.audit",
+				Query:      `{"term":{"cluster":"main"}}`,
+			}, nil, nil).config,
+			want: `{
+				"size": 10,
+				"query": {"bool": {"filter": [
+					{"terms": {"kubernetes.audit.verb": ["patch", "create", "delete"]}},
+					{"match": {"kubernetes.audit.stage": "ResponseComplete"}},
+					{"term": {"cluster": "main"}},
+					{"range": {"kubernetes.audit.stageTimestamp": {"gte": "2024-07-01T12:00:00Z"}}}
+				]}},
+				"sort": [{"kubernetes.audit.stageTimestamp": "asc"}, {"kubernetes.audit.auditID.keyword": "asc"}]
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := &searcher{
+				config:    tt.config,
+				filter:    auditfilter.Default(),
+				startedAt: startedAt,
+				cursor:    sortValues(t, tt.cursor),
+				after:     sortValues(t, tt.after),
+			}
+			got, err := json.Marshal(sr.buildRequest())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("buildRequest() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_isAfterCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+		want   bool
+	}{
+		{name: "no cursor", sort: `[1000, "a1"]`, want: true},
+		{name: "newer", cursor: `[1000, "a1"]`, sort: `[2000, "a2"]`, want: true},
+		{name: "same time", cursor: `[1000, "a1"]`, sort: `[1000, "a2"]`, want: true},
+		{name: "older, within the overlap", cursor: `[2000, "a2"]`, sort: `[1000, "a1"]`},
+		{name: "cursor without timestamp", cursor: `["2024-07-01T13:00:00Z", "a1"]`, sort: `[1000, "a1"]`, want: true},
+		{name: "hit without timestamp", cursor: `[1000, "a1"]`, sort: `["2024-07-01T13:00:00Z", "a1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := &searcher{cursor: sortValues(t, tt.cursor)}
+			if got := sr.isAfterCursor(sortValues(t, tt.sort)); got != tt.want {
+				t.Errorf("isAfterCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_pollPicksUpLateHits(t *testing.T) {
+	t0 := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	server := &fakeSearchServer{}
+	server.index("a1", t0.Add(time.Minute))
+	server.index("a2", t0.Add(time.Minute*3))
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	var handled []string
+	store := newFakeStore()
+	sr := &searcher{
+		client:     srv.Client(),
+		config:     NewSource(Config{URL: srv.URL, Index: "audit-*"}, nil, nil).config,
+		checkpoint: "opensearch:audit-*",
+		store:      store,
+		filter:     auditfilter.Default(),
+		handle: func(_ context.Context, event source.Event) error {
+			handled = append(handled, event.Actor.Principal)
+			return nil
+		},
+		startedAt: t0,
+	}
+
+	if err := sr.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1", "a2"}; !slices.Equal(handled, want) {
+		t.Errorf("handled %q, want %q", handled, want)
+	}
+
+	// Indexed after the previous poll, but older than the cursor
+	server.index("a3", t0.Add(time.Minute*2))
+	if err := sr.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1", "a2", "a3"}; !slices.Equal(handled, want) {
+		t.Errorf("handled %q, want %q", handled, want)
+	}
+
+	// The cursor remains at the newest hit, and is persisted
+	cursor, err := sr.loadCursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := sortTime(cursor); !ok || !got.Equal(t0.Add(time.Minute*3)) {
+		t.Errorf("unexpected cursor: %s", cursor)
+	}
+}
+
+func sortValues(t *testing.T, raw string) []json.RawMessage {
+	t.Helper()
+	if raw == "" {
+		return nil
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var av, bv any
+	if err := json.Unmarshal(a, &av); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+type fakeHit struct {
+	auditID   string
+	timestamp time.Time
+}
+
+// fakeSearchServer serves searches, returning the documents indexed so far within the range filter, in sort order. The
+// audit event of each document is attributed to a principal named after its audit ID.
+type fakeSearchServer struct {
+	mu   sync.Mutex
+	hits []fakeHit
+}
+
+func (s *fakeSearchServer) index(auditID string, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits = append(s.hits, fakeHit{auditID: auditID, timestamp: timestamp})
+	slices.SortFunc(s.hits, func(a, b fakeHit) int { return a.timestamp.Compare(b.timestamp) })
+}
+
+func (s *fakeSearchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query struct {
+			Bool struct {
+				Filter []struct {
+					Range map[string]struct {
+						GTE time.Time `json:"gte"`
+					} `json:"range"`
+				} `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		SearchAfter []json.RawMessage `json:"search_after"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var from time.Time
+	for _, filter := range req.Query.Bool.Filter {
+		for _, r := range filter.Range {
+			from = r.GTE
+		}
+	}
+	after, _ := sortTime(req.SearchAfter)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hits := make([]map[string]any, 0, len(s.hits))
+	for _, hit := range s.hits {
+		if hit.timestamp.Before(from) || (req.SearchAfter != nil && !hit.timestamp.After(after)) {
+			continue
+		}
+		hits = append(hits, map[string]any{
+			"_id": hit.auditID,
+			"_source": map[string]any{
+				"auditID": hit.auditID,
+				"stage":   "ResponseComplete",
+				"verb":    "patch",
+				"user":    map[string]any{"username": hit.auditID},
+				"objectRef": map[string]any{
+					"resource":   "kustomizations",
+					"namespace":  "flux-system",
+					"name":       "podinfo",
+					"apiGroup":   "kustomize.toolkit.fluxcd.io",
+					"apiVersion": "v1",
+				},
+				"stageTimestamp": hit.timestamp,
+			},
+			"sort": []any{hit.timestamp.UnixMilli(), hit.auditID},
+		})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"hits": hits}})
+}
+
+// fakeStore is an in-memory store
+type fakeStore struct {
+	checkpoints map[string][]byte
+	seen        map[string]struct{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		checkpoints: make(map[string][]byte),
+		seen:        make(map[string]struct{}),
+	}
+}
+
+func (s *fakeStore) GetCheckpoint(name string) ([]byte, error) {
+	value, ok := s.checkpoints[name]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fakeStore) SaveCheckpoint(name string, value []byte) error {
+	s.checkpoints[name] = value
+	return nil
+}
+
+func (s *fakeStore) HasSeen(key string) (bool, error) {
+	_, ok := s.seen[key]
+	return ok, nil
+}
+
+func (s *fakeStore) MarkSeen(key string, _ time.Duration) error {
+	s.seen[key] = struct{}{}
+	return nil
+}
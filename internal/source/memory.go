@@ -0,0 +1,53 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+)
+
+// Memory is a Source implementation where events are published directly, rather than read from an audit backend.
+// It is primarily intended for use in tests.
+type Memory struct {
+	events chan Event
+}
+
+// NewMemory instantiates and returns Memory. Up to buffer events can be published before Publish blocks.
+func NewMemory(buffer int) *Memory {
+	return &Memory{
+		events: make(chan Event, buffer),
+	}
+}
+
+// Publish queues an event for delivery to the handler passed to Run. It must not be called after Close.
+func (m *Memory) Publish(ctx context.Context, event Event) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case m.events <- event:
+		return nil
+	}
+}
+
+// Close signals that no further events will be published. Run returns once all queued events have been handled.
+func (m *Memory) Close() {
+	close(m.events)
+}
+
+// Run passes published events to the handler
+func (m *Memory) Run(ctx context.Context, _ []k8s.ResourceType, handle Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-m.events:
+			if !ok {
+				return nil
+			}
+			if err := handle(ctx, event); err != nil {
+				return fmt.Errorf("handler failed: %w", err)
+			}
+		}
+	}
+}
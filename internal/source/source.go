@@ -0,0 +1,47 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+)
+
+// Event is the normalised form of an audit event; it describes a resource having been mutated by an actor at a given
+// point in time
+type Event struct {
+	Resource k8s.ResourceReference
+	Actor    actor.Actor
+	Time     time.Time
+	// Object optionally holds the raw resource as it was immediately after the mutation, if known to the source. When
+	// not set, the current state of the resource is fetched from the kubernetes API.
+	Object []byte
+	// Request optionally holds the raw request body (e.g. a patch), if known to the source. When Object is not set, the
+	// suspension status it sets is evaluated, so that the outcome of this specific operation is evaluated rather than
+	// the current state.
+	Request []byte
+	// Created is true if the resource was created by the operation, rather than modified
+	Created bool
+	// Deleted is true if the resource was deleted by the operation
+	Deleted bool
+}
+
+// Handler is invoked by a Source for each event observed
+type Handler func(context.Context, Event) error
+
+// Source is the interface that is expected to be implemented by audit event backends
+type Source interface {
+	// Run streams events relating to the supplied resource types to the handler. It blocks until the context is
+	// cancelled, or an unrecoverable error occurs. Any error returned by the handler is considered unrecoverable.
+	Run(ctx context.Context, types []k8s.ResourceType, handle Handler) error
+}
+
+// Scoped is implemented by sources that only observe the resource types supplied to Run, such as those watching the
+// kubernetes API. Scoped sources are restarted when the suspendable resource types change, whereas other sources
+// observe all audit events, and so are left running.
+type Scoped interface {
+	Source
+	// ScopedToTypes is a marker method, and does nothing
+	ScopedToTypes()
+}
@@ -0,0 +1,40 @@
+package watch
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// permanentError marks an error that will recur however many times the event is retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// permanent marks the error as permanent, so that the event is not retried
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent classifies an error returned while handling an event. Errors are assumed to be transient (e.g. network
+// failures, API server or Slack unavailability) unless known to be permanent.
+func isPermanent(err error) bool {
+	var permanentErr *permanentError
+	if errors.As(err, &permanentErr) {
+		return true
+	}
+
+	return apierrors.IsNotFound(err) ||
+		apierrors.IsGone(err) ||
+		apierrors.IsBadRequest(err) ||
+		apierrors.IsInvalid(err) ||
+		apierrors.IsMethodNotSupported(err)
+}
@@ -2,48 +2,106 @@ package watch
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"slices"
+	"sync"
 	"time"
 
-	"google.golang.org/genproto/googleapis/cloud/audit"
+	"golang.org/x/sync/errgroup"
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditlog"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/discovery"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/fluxcd"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/metrics"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/notification"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
 )
 
+const (
+	// retryInterval is how often the retry queue is checked for events that are due
+	retryInterval = time.Second * 5
+	// retryBaseBackoff and retryMaxBackoff bound the delay between attempts to handle an event
+	retryBaseBackoff = time.Second * 10
+	retryMaxBackoff  = time.Minute * 30
+	// maxRetryAttempts is how many attempts are made to handle an event before it is recorded as failed
+	maxRetryAttempts = 10
+)
+
+// reconciliationActor is attributed changes detected by reconciliation, as the identity responsible is unknown
+var reconciliationActor = actor.Actor{Principal: actor.UnknownPrincipal}
+
 // Watcher is used to orchestrate notifications. It discovers fluxcd resources, watches for changes, and notifies when
 // the suspension status changes.
 type Watcher struct {
+	cluster              string
 	googleCloudProjectID string
-	gkeClusterName       string
+	source               source.Source
+	discovery            *discovery.Selector
 	k8sClient            k8sClient
 	store                store
 	notifier             notifier
+	logger               *slog.Logger
+	// definitions describe how resource types other than those of fluxcd express their suspension status
+	definitions []fluxcd.Definition
+	// auditFilter is that of the source, if any, which is extended with the API groups of the suspendable types
+	auditFilter *auditfilter.Filter
+	// notifyOnDiscovery causes resources seen for the first time to be notified if suspended by the operation, rather
+	// than silently recorded
+	notifyOnDiscovery bool
+	// reconcileInterval is how often the cluster is reconciled against the store, after initialization. Zero disables
+	// periodic reconciliation.
+	reconcileInterval time.Duration
+	// drift holds the resources found to differ from the store by the previous reconciliation, which are reported if
+	// they still differ at the next
+	drift map[string]struct{}
+	// mu serialises event handling, as events are handled by the source, the retry loop and reconciliation
+	mu sync.Mutex
+	// types holds the suspendable resource types, which change as custom resource definitions are installed and
+	// removed. restartSource stops the current run of a scoped source, so that it is started with the new types.
+	types         []k8s.ResourceType
+	restartSource context.CancelFunc
+	typesMu       sync.RWMutex
 }
 
-// NewWatcher instantiates and returns Watcher
+// NewWatcher instantiates and returns Watcher. Custom resource definitions are selected by the discovery selector, or
+// the default selector if nil. The resource types of the supplied definitions are watched in addition, where served
+// by the cluster. The audit filter used by the source, if any, is extended with the API groups of the suspendable
+// resource types, so that types selected only by label are not filtered out.
 func NewWatcher(
+	cluster string,
 	googleCloudProjectID string,
-	gkeClusterName string,
+	src source.Source,
+	discoverySelector *discovery.Selector,
+	auditFilter *auditfilter.Filter,
+	definitions []fluxcd.Definition,
 	k8sClient k8sClient,
 	store store,
 	notifier notifier,
+	reconcileInterval time.Duration,
 ) *Watcher {
+	if discoverySelector == nil {
+		discoverySelector = discovery.Default()
+	}
 	return &Watcher{
+		cluster:              cluster,
 		googleCloudProjectID: googleCloudProjectID,
-		gkeClusterName:       gkeClusterName,
+		source:               src,
+		discovery:            discoverySelector,
+		auditFilter:          auditFilter,
+		definitions:          definitions,
 		k8sClient:            k8sClient,
 		store:                store,
 		notifier:             notifier,
+		logger:               slog.With(slog.String("cluster", cluster)),
+		reconcileInterval:    reconcileInterval,
 	}
 }
 
@@ -51,11 +109,18 @@ type k8sClient interface {
 	GetRawResource(ctx context.Context, resource k8s.ResourceReference) ([]byte, error)
 	GetRawResources(ctx context.Context, group k8s.ResourceType) ([]byte, error)
 	GetCustomResourceDefinitions(ctx context.Context, listOptions metav1.ListOptions) (*v1.CustomResourceDefinitionList, error)
+	WatchCustomResourceDefinitions(ctx context.Context, labelSelector string, changed func()) error
+	ServesResourceType(t k8s.ResourceType) (bool, error)
 }
 
 type store interface {
 	GetEntry(k8s.ResourceReference) (datastore.Entry, error)
 	SaveEntry(datastore.Entry) error
+	Entries() ([]datastore.Entry, error)
+	SaveRetry(datastore.Retry) error
+	DeleteRetry(id string) error
+	DueRetries(now time.Time) ([]datastore.Retry, error)
+	SaveFailure(datastore.Failure) error
 }
 
 type notifier interface {
@@ -70,26 +135,48 @@ func (w *Watcher) Watch(ctx context.Context) error {
 		return fmt.Errorf("could not resolve flux resource types: %w", err)
 	}
 
+	w.types = resourceTypes
+	w.extendAuditFilter(resourceTypes)
+
 	if err = w.init(ctx, resourceTypes); err != nil {
 		return fmt.Errorf("failed to initialize: %w", err)
 	}
 
-	return w.watch(ctx, resourceTypes)
+	return w.watch(ctx)
 }
 
-// resolveFluxResourceTypes returns fluxcd resource types; specifically only those that can be suspended.
+// resolveFluxResourceTypes returns the resource types of the custom resource definitions selected for discovery (by
+// default, those of fluxcd); specifically only those that can be suspended. The resource types of the definitions
+// are included where served.
 func (w *Watcher) resolveFluxResourceTypes(ctx context.Context) ([]k8s.ResourceType, error) {
-	crds, err := w.k8sClient.GetCustomResourceDefinitions(ctx, metav1.ListOptions{
-		LabelSelector: "app.kubernetes.io/part-of=flux",
-	})
+	crds, err := w.k8sClient.GetCustomResourceDefinitions(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch crds: %w", err)
 	}
 
-	types := make([]k8s.ResourceType, 0, len(crds.Items))
+	types := make([]k8s.ResourceType, 0, len(crds.Items)+len(w.definitions))
+	for _, def := range w.definitions {
+		served, err := w.k8sClient.ServesResourceType(def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check whether %s is served: %w", def.Type.GroupVersionResource(), err)
+		}
+		if served {
+			types = append(types, def.Type)
+		}
+	}
 	for _, crd := range crds.Items {
+		if !w.discovery.Matches(crd) {
+			continue
+		}
+		// Types with a definition have already been included, with the version it names
+		if isWatched(w.definitionTypes(), k8s.ResourceType{Group: crd.Spec.Group, Kind: crd.Spec.Names.Plural}) {
+			continue
+		}
 		for _, version := range crd.Spec.Versions {
 			// We're only interested in resources that can be suspended
+			if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
 			if _, exists := version.Schema.OpenAPIV3Schema.Properties["spec"].Properties["suspend"]; !exists {
 				continue
 			}
@@ -103,11 +190,54 @@ func (w *Watcher) resolveFluxResourceTypes(ctx context.Context) ([]k8s.ResourceT
 	return types, nil
 }
 
+// extendAuditFilter adds the API groups of the resource types to the audit filter, where not already matched. The
+// groups added are returned.
+func (w *Watcher) extendAuditFilter(types []k8s.ResourceType) []string {
+	if w.auditFilter == nil {
+		return nil
+	}
+	groups := make([]string, 0, len(types))
+	for _, t := range types {
+		groups = append(groups, t.Group)
+	}
+	added := w.auditFilter.AddAPIGroups(groups...)
+	for _, group := range added {
+		w.logger.Info("audit filter extended with api group of discovered resource type", slog.String("group", group))
+	}
+	return added
+}
+
+// definitionTypes returns the resource types of the definitions
+func (w *Watcher) definitionTypes() []k8s.ResourceType {
+	types := make([]k8s.ResourceType, 0, len(w.definitions))
+	for _, def := range w.definitions {
+		types = append(types, def.Type)
+	}
+	return types
+}
+
+// definition returns the definition of how the resource type expresses its suspension status
+func (w *Watcher) definition(t k8s.ResourceType) fluxcd.Definition {
+	return fluxcd.DefinitionFor(w.definitions, t)
+}
+
 // init retries the suspension status of all fluxcd resource instances that are a suspendable resource type. This is
 // useful when starting from scratch, to build an initial picture. Equally, if the application has been down for a
 // period of time, it allows for the state to be synchronised.
 func (w *Watcher) init(ctx context.Context, types []k8s.ResourceType) error {
-	slog.Info("initializing")
+	w.logger.Info("initializing")
+	return w.reconcile(ctx, types, false)
+}
+
+// listedResource is a resource returned by listResources
+type listedResource struct {
+	ref      k8s.ResourceReference
+	resource fluxcd.Resource
+}
+
+// listResources fetches all instances of the supplied resource types
+func (w *Watcher) listResources(ctx context.Context, types []k8s.ResourceType) ([]listedResource, error) {
+	var listed []listedResource
 	seen := make(map[string]struct{})
 	for _, t := range types {
 		// We only need to fetch against one version per group+kind
@@ -120,122 +250,728 @@ func (w *Watcher) init(ctx context.Context, types []k8s.ResourceType) error {
 		// Fetch raw fluxcd resource for this specific type
 		res, err := w.k8sClient.GetRawResources(ctx, t)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		var resourceList fluxcd.ResourceList
-		if err = json.Unmarshal(res, &resourceList); err != nil {
-			return fmt.Errorf("failed to unmarshal resource: %w", err)
+		resources, err := w.definition(t).DecodeList(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
 		}
-		for _, resource := range resourceList.Items {
-			resourceRef := k8s.ResourceReference{
-				Type:      t,
-				Namespace: resource.Metadata.Namespace,
-				Name:      resource.Metadata.Name,
-			}
-			if err = w.processResource(ctx, resourceRef, resource, "<unknown>"); err != nil {
-				return fmt.Errorf("failed to process resource: %w", err)
+		for _, resource := range resources {
+			listed = append(listed, listedResource{
+				ref: k8s.ResourceReference{
+					Type:      t,
+					Namespace: resource.Metadata.Namespace,
+					Name:      resource.Metadata.Name,
+				},
+				resource: resource,
+			})
+		}
+	}
+	return listed, nil
+}
+
+// reconcileLoop periodically reconciles the cluster against the store, until the context is cancelled. A failed
+// reconciliation is logged, and attempted again at the next interval.
+func (w *Watcher) reconcileLoop(ctx context.Context) error {
+	ticker := time.NewTicker(w.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		w.logger.Debug("reconciling")
+		if err := w.reconcile(ctx, w.watchedTypes(), true); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
+			w.logger.Warn("reconciliation failed, will retry", slog.Any("error", err))
+		}
+	}
+}
+
+// reconcile lists all resources of the supplied types, and compares them against the store. Suspension changes and
+// deletions that were not observed via the source are notified as detected by reconciliation. When deferred, drift is
+// only reported if it persists until the next reconciliation, as the audit event for a recent change may simply not
+// have been received yet. Only deferred (i.e. periodic) reconciliations count towards the drift metric, as differences
+// found at startup, or when a resource type is added, are expected.
+func (w *Watcher) reconcile(ctx context.Context, types []k8s.ResourceType, deferDrift bool) error {
+	// The listing reflects the state at this time, so it is compared against events handled before it, but not after
+	listedAt := time.Now().UTC()
+	listed, err := w.listResources(ctx, types)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	drift := make(map[string]struct{})
+	report := func(ref k8s.ResourceReference) bool {
+		key := resourceKey(ref)
+		if !deferDrift {
+			return true
+		}
+		if _, ok := w.drift[key]; !ok {
+			drift[key] = struct{}{}
+			return false
+		}
+		metrics.ReconciliationDrift.WithLabelValues(w.cluster).Inc()
+		return true
+	}
+
+	exists := make(map[string]struct{}, len(listed))
+	for _, l := range listed {
+		exists[resourceKey(l.ref)] = struct{}{}
+
+		// Resources pending deletion remain listed until their finalizers complete. They are neither compared, nor
+		// reported as deleted, until they are gone.
+		if l.resource.Metadata.DeletionTimestamp != nil {
+			continue
+		}
+
+		entry, err := w.store.GetEntry(l.ref)
+		switch {
+		case errors.Is(err, datastore.ErrNotFound) || (err == nil && entry.Tombstoned):
+			// Discovered, or possibly recreated
+		case err != nil:
+			return fmt.Errorf("failed to fetch entry: %w", err)
+		case entry.Suspended == l.resource.Suspended || entry.IsNewerThan(l.resource.Metadata.Generation, listedAt):
+			continue
+		case !report(l.ref):
+			continue
+		}
+
+		if err = w.processResource(ctx, l.ref, l.resource, reconciliationActor, listedAt, originReconciliation); err != nil {
+			return fmt.Errorf("failed to process resource: %w", err)
+		}
+	}
+
+	entries, err := w.store.Entries()
+	if err != nil {
+		return fmt.Errorf("failed to fetch entries: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Tombstoned || !isWatched(types, entry.Resource.Type) || entry.IsNewerThan(0, listedAt) {
+			continue
+		}
+		if _, ok := exists[resourceKey(entry.Resource)]; ok || !report(entry.Resource) {
+			continue
+		}
+		if err = w.processDeletion(ctx, entry.Resource, reconciliationActor, listedAt, originReconciliation); err != nil {
+			return fmt.Errorf("failed to process deletion: %w", err)
 		}
 	}
+
+	if deferDrift {
+		w.drift = drift
+	}
 	return nil
 }
 
-// watch tails audit logs, waiting for modifications to fluxcd resource types that are suspendable. When a modification
-// is observed, the resource state is evaluated via processResource
-func (w *Watcher) watch(ctx context.Context, types []k8s.ResourceType) error {
-	slog.Info("watching for resource modifications")
+// resourceKey identifies a resource irrespective of the version it was fetched with
+func resourceKey(ref k8s.ResourceReference) string {
+	return fmt.Sprintf("%s:%s:%s:%s", ref.Type.Group, ref.Type.Kind, ref.Namespace, ref.Name)
+}
 
-	return auditlog.Tail(ctx, w.googleCloudProjectID, w.gkeClusterName, func(logEntry *audit.AuditLog) error {
-		if code := logEntry.GetStatus().GetCode(); code != 0 {
-			slog.Warn("operation appeared to fail", slog.Int("code", int(code)))
+// isWatched returns true if the resource type is one of the supplied types, irrespective of version
+func isWatched(types []k8s.ResourceType, t k8s.ResourceType) bool {
+	return slices.ContainsFunc(types, func(watched k8s.ResourceType) bool {
+		return watched.Group == t.Group && watched.Kind == t.Kind
+	})
+}
+
+// watch consumes audit events from the source, waiting for modifications to fluxcd resource types that are
+// suspendable. When a modification is observed, the resource state is evaluated via processResource. Failures to
+// handle individual events are isolated, so that the source keeps running; events that failed transiently are retried
+// in the background. Custom resource definitions are watched alongside, so that suspendable types installed or removed
+// later are taken into account.
+func (w *Watcher) watch(ctx context.Context) error {
+	w.logger.Info("watching for resource modifications")
+
+	discovered := make(chan struct{}, 1)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return w.runSource(ctx)
+	})
+	g.Go(func() error {
+		return w.retry(ctx)
+	})
+	g.Go(func() error {
+		// All definitions are watched, as the discovery selector may select by more than labels
+		return w.k8sClient.WatchCustomResourceDefinitions(ctx, "", func() {
+			select {
+			case discovered <- struct{}{}:
+			default: // A refresh is already pending
+			}
+		})
+	})
+	g.Go(func() error {
+		return w.discover(ctx, discovered)
+	})
+	if w.reconcileInterval > 0 {
+		g.Go(func() error {
+			return w.reconcileLoop(ctx)
+		})
+	}
+	return g.Wait()
+}
+
+// runSource runs the source until the context is cancelled. Scoped sources are restarted whenever the suspendable
+// resource types change.
+func (w *Watcher) runSource(ctx context.Context) error {
+	// Events are handled with the parent context rather than that of the run, so that an event in flight when the
+	// source is restarted is still handled (or queued to be retried), as it will not be delivered again
+	handle := func(_ context.Context, event source.Event) error {
+		if !slices.Contains(w.watchedTypes(), event.Resource.Type) {
+			w.logger.Info("ignoring non-watched resource", slog.String("kind", event.Resource.Type.Kind))
 			return nil
 		}
 
-		resourceName := logEntry.GetResourceName()
-		email := logEntry.GetAuthenticationInfo().GetPrincipalEmail()
+		return w.isolate(ctx, event, nil)
+	}
 
-		resourceRef, err := k8s.ResourceReferenceFromPath(resourceName)
-		if err != nil {
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		w.typesMu.Lock()
+		types := slices.Clone(w.types)
+		w.restartSource = cancel
+		w.typesMu.Unlock()
+
+		err := w.source.Run(runCtx, types, handle)
+		restarted := runCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if !restarted {
 			return err
 		}
+		w.logger.Info("restarting source, as resource types changed")
+	}
+}
 
-		if !slices.Contains(types, resourceRef.Type) {
-			slog.Info("ignoring non-watched resource", slog.String("kind", resourceRef.Type.Kind))
-			return nil
+// watchedTypes returns the suspendable resource types currently watched
+func (w *Watcher) watchedTypes() []k8s.ResourceType {
+	w.typesMu.RLock()
+	defer w.typesMu.RUnlock()
+	return w.types
+}
+
+// discover refreshes the suspendable resource types whenever a signal is received, until the context is cancelled.
+// A failed refresh is logged, and attempted again at the next signal.
+func (w *Watcher) discover(ctx context.Context, discovered <-chan struct{}) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-discovered:
 		}
 
-		res, err := w.k8sClient.GetRawResource(ctx, resourceRef)
-		if err != nil {
-			return fmt.Errorf("failed to get raw resource: %w", err)
+		if err := w.refreshTypes(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.logger.Warn("failed to refresh resource types", slog.Any("error", err))
 		}
+	}
+}
 
-		var resource fluxcd.Resource
-		if err = json.Unmarshal(res, &resource); err != nil {
-			return fmt.Errorf("failed to unmarshal resource: %w", err)
+// refreshTypes resolves the suspendable resource types, and compares them against those currently watched. Resources
+// of newly added types are listed in the same way as during initialization, and scoped sources are restarted.
+func (w *Watcher) refreshTypes(ctx context.Context) error {
+	types, err := w.resolveFluxResourceTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("could not resolve flux resource types: %w", err)
+	}
+
+	current := w.watchedTypes()
+	var added, removed []k8s.ResourceType
+	for _, t := range types {
+		if !slices.Contains(current, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range current {
+		if !slices.Contains(types, t) {
+			removed = append(removed, t)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	for _, t := range added {
+		w.logger.Info(
+			"resource type added",
+			slog.String("group", t.Group),
+			slog.String("version", t.Version),
+			slog.String("kind", t.Kind),
+		)
+	}
+	for _, t := range removed {
+		w.logger.Info(
+			"resource type removed",
+			slog.String("group", t.Group),
+			slog.String("version", t.Version),
+			slog.String("kind", t.Kind),
+		)
+	}
+
+	// The types are updated before listing, so that events for the added types are not ignored in the meantime. Other
+	// sources are also restarted if the audit filter was extended, as they may filter server-side.
+	extended := w.extendAuditFilter(types)
+	w.typesMu.Lock()
+	w.types = types
+	if _, ok := w.source.(source.Scoped); (ok || len(extended) > 0) && w.restartSource != nil {
+		w.restartSource()
+	}
+	w.typesMu.Unlock()
+
+	// Only kinds not already watched need to be listed, rather than new versions of existing kinds
+	var addedKinds []k8s.ResourceType
+	for _, t := range added {
+		if !isWatched(current, t) {
+			addedKinds = append(addedKinds, t)
+		}
+	}
+	if len(addedKinds) > 0 {
+		if err = w.reconcile(ctx, addedKinds, false); err != nil {
+			return fmt.Errorf("failed to initialize added resource types: %w", err)
+		}
+	}
+	return nil
+}
+
+// Replay consumes events from the source without resolving resource types, initializing, or consulting the kubernetes
+// API. It is used to reconstruct history from exported audit logs. Only deletions, and events whose request sets the
+// suspension status, are replayed. Where a resource is seen for the first time, an operation suspending it is taken
+// to be a change and notified; other operations are silently recorded, as the previous state is unknown.
+func (w *Watcher) Replay(ctx context.Context) error {
+	w.notifyOnDiscovery = true
+
+	return w.source.Run(ctx, nil, func(ctx context.Context, event source.Event) error {
+		if !event.Deleted {
+			if _, ok := w.definition(event.Resource.Type).RequestedSuspend(event.Request); !ok {
+				return nil
+			}
+		}
+		return w.handleEvent(ctx, event)
+	})
+}
+
+// isolate handles the event, such that a failure does not stop the source. Transient failures are queued to be retried
+// with backoff, and permanent failures (or those that have exhausted their retries) are recorded. The retry the event
+// originates from, if any, is supplied. Only failures to persist the outcome are returned.
+func (w *Watcher) isolate(ctx context.Context, event source.Event, retry *datastore.Retry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	handleErr := w.handleEvent(ctx, event)
+	switch {
+	case handleErr == nil:
+		if retry != nil {
+			return w.store.DeleteRetry(retry.ID)
 		}
+		return nil
+	case ctx.Err() != nil:
+		return handleErr // Shutting down, so the event will be handled on restart
+	}
 
-		if err = w.processResource(ctx, resourceRef, resource, email); err != nil {
-			return fmt.Errorf("failed to re-check suspension status: %w", err)
+	queued := retry != nil
+	if !queued {
+		retry = &datastore.Retry{
+			ID:    fmt.Sprintf("%020d", time.Now().UnixNano()),
+			Event: event,
 		}
+	}
+	retry.Attempts++
+	retry.LastError = handleErr.Error()
+
+	logger := w.logger.With(
+		slog.Any("error", handleErr),
+		slog.String("resource", event.Resource.Path()),
+		slog.String("actor", event.Actor.String()),
+		slog.Time("occurredAt", event.Time),
+		slog.Int("attempts", retry.Attempts),
+	)
 
+	if isPermanent(handleErr) || retry.Attempts >= maxRetryAttempts {
+		logger.Error("failed to handle event, giving up")
+		metrics.EventFailures.WithLabelValues(w.cluster).Inc()
+
+		if err := w.store.SaveFailure(datastore.Failure{
+			Event:    event,
+			Attempts: retry.Attempts,
+			Error:    handleErr.Error(),
+			FailedAt: time.Now().UTC(),
+		}); err != nil {
+			return fmt.Errorf("failed to save failure: %w", err)
+		}
+		if queued {
+			return w.store.DeleteRetry(retry.ID)
+		}
 		return nil
-	})
+	}
+
+	retry.NextAttempt = time.Now().UTC().Add(retryBackoff(retry.Attempts))
+	logger.Warn("failed to handle event, will retry", slog.Time("nextAttempt", retry.NextAttempt))
+	metrics.EventRetries.WithLabelValues(w.cluster).Inc()
+
+	if err := w.store.SaveRetry(*retry); err != nil {
+		return fmt.Errorf("failed to save retry: %w", err)
+	}
+	return nil
+}
+
+// retry periodically handles queued events whose next attempt is due, until the context is cancelled
+func (w *Watcher) retry(ctx context.Context) error {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		retries, err := w.store.DueRetries(time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed to fetch retries: %w", err)
+		}
+		for _, retry := range retries {
+			if err = w.isolate(ctx, retry.Event, &retry); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// retryBackoff returns the delay before the next attempt, which doubles with each attempt up to a limit
+func retryBackoff(attempts int) time.Duration {
+	backoff := retryBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return backoff
+}
+
+// handleEvent evaluates the state of the resource an event relates to. The outcome of the operation carried by the
+// event is used if present, otherwise the resource is fetched.
+func (w *Watcher) handleEvent(ctx context.Context, event source.Event) error {
+	if event.Deleted {
+		if err := w.processDeletion(ctx, event.Resource, event.Actor, event.Time, eventOrigin(event)); err != nil {
+			return fmt.Errorf("failed to process deletion: %w", err)
+		}
+		return nil
+	}
+
+	resource, ok, err := w.resolveResource(ctx, event)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err = w.processResource(ctx, event.Resource, resource, event.Actor, event.Time, eventOrigin(event)); err != nil {
+		return fmt.Errorf("failed to re-check suspension status: %w", err)
+	}
+
+	return nil
+}
+
+// origin describes how a change to a resource was observed
+type origin int
+
+const (
+	// originUpdate is an operation on an existing resource (or one whose creation is not known)
+	originUpdate origin = iota
+	// originCreate is an operation that created the resource
+	originCreate
+	// originReconciliation is a change found by listing resources, rather than by an operation
+	originReconciliation
+)
+
+// eventOrigin returns the origin of the change described by the event
+func eventOrigin(event source.Event) origin {
+	if event.Created {
+		return originCreate
+	}
+	return originUpdate
+}
+
+// resolveResource determines the state of the resource immediately after the operation described by the event. The
+// resulting object, or the suspension status requested, is preferred over fetching the resource, as the resource may
+// have since been modified again or deleted. The resource is only fetched if neither is known. False is returned if
+// the resource no longer exists, or if the request did not change the suspension status, in which case only the
+// observation is recorded.
+func (w *Watcher) resolveResource(ctx context.Context, event source.Event) (fluxcd.Resource, bool, error) {
+	def := w.definition(event.Resource.Type)
+
+	if event.Object != nil {
+		resource, err := def.Decode(event.Object)
+		if err != nil {
+			return resource, false, permanent(fmt.Errorf("failed to unmarshal resource: %w", err))
+		}
+		return resource, true, nil
+	}
+
+	if suspended, ok := def.RequestedSuspend(event.Request); ok {
+		var resource fluxcd.Resource
+		resource.Metadata.Name = event.Resource.Name
+		resource.Metadata.Namespace = event.Resource.Namespace
+		resource.Suspended = suspended
+		return resource, true, nil
+	}
+	if len(event.Request) > 0 {
+		// The request did not set the field, so the suspension status was not changed. Fetching the resource would
+		// credit this operation with any change made since.
+		return fluxcd.Resource{}, false, w.recordObservation(event.Resource, event.Time)
+	}
+
+	res, err := w.k8sClient.GetRawResource(ctx, event.Resource)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			w.logger.Info(
+				"resource no longer exists, ignoring",
+				slog.String("kind", event.Resource.Type.Kind),
+				slog.String("resource", event.Resource.Name),
+			)
+			return fluxcd.Resource{}, false, nil
+		}
+		return fluxcd.Resource{}, false, fmt.Errorf("failed to get raw resource: %w", err)
+	}
+	resource, err := def.Decode(res)
+	if err != nil {
+		return resource, false, permanent(fmt.Errorf("failed to unmarshal resource: %w", err))
+	}
+	return resource, true, nil
 }
 
 // processResource checks to see if the suspend status has been modified. If it has, a notification is dispatched. If
-// the resource has never been seen before, we simply save the state.
+// the resource has never been seen before, we simply save the state (unless notifying on discovery, and the operation
+// suspended an existing resource). Events older than the stored state (e.g. delayed
+// or redelivered) are discarded, so that they cannot overwrite a newer state or misattribute a change.
 func (w *Watcher) processResource(
 	ctx context.Context,
 	resourceRef k8s.ResourceReference,
 	resource fluxcd.Resource,
-	updatedBy string,
+	updatedBy actor.Actor,
+	occurredAt time.Time,
+	origin origin,
 ) error {
 	entry, err := w.store.GetEntry(resourceRef)
-	if err != nil {
-		if errors.Is(err, datastore.ErrNotFound) {
-			// First time seeing the resource, so we'll save the state, but not notify - as we don't know what has
-			// changed
-			slog.Info(
-				"new resource discovered",
+	if err == nil && entry.Tombstoned {
+		if !isRecreated(entry, resource, occurredAt, origin == originCreate) {
+			w.logger.Warn(
+				"discarding event for deleted resource",
 				slog.String("kind", resourceRef.Type.Kind),
 				slog.String("resource", resourceRef.Name),
-				slog.Bool("suspended", resource.Spec.Suspend),
+				slog.Time("occurredAt", occurredAt),
+				slog.Time("deletedAt", entry.DeletedAt),
 			)
-			return w.store.SaveEntry(datastore.Entry{
-				Resource:  resourceRef,
-				Suspended: resource.Spec.Suspend,
-				UpdatedBy: updatedBy,
-				UpdatedAt: time.Now().UTC(),
-			})
+			return nil
 		}
+		// The resource has since been recreated. Its generation restarts, so it is treated as newly discovered.
+		err = datastore.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, datastore.ErrNotFound) && w.notifyOnDiscovery && resource.Suspended && origin != originCreate:
+		// The operation suspended an existing resource, which is taken to mean it was previously active. Operations
+		// that create a resource, or set the field to a value other than suspended (e.g. a server-side apply of the
+		// whole spec), may not have changed anything, so are recorded silently below.
+		entry = datastore.Entry{}
+	case errors.Is(err, datastore.ErrNotFound):
+		// First time seeing the resource, so we'll save the state, but not notify - as we don't know what has
+		// changed
+		w.logger.Info(
+			"new resource discovered",
+			slog.String("kind", resourceRef.Type.Kind),
+			slog.String("resource", resourceRef.Name),
+			slog.Bool("suspended", resource.Suspended),
+		)
+		entry = datastore.Entry{
+			Resource:  resourceRef,
+			Suspended: resource.Suspended,
+			UpdatedBy: updatedBy.Principal,
+			UpdatedAt: occurredAt,
+			Actor:     updatedBy,
+		}
+		observe(&entry, resource, occurredAt)
+		return w.store.SaveEntry(entry)
+	case err != nil:
 		return fmt.Errorf("failed to fetch entry: %w", err)
 	}
 
-	if resource.Spec.Suspend == entry.Suspended {
-		return nil // Probably something else about the resource modified
+	if entry.IsNewerThan(resource.Metadata.Generation, occurredAt) {
+		w.logger.Warn(
+			"discarding stale event",
+			slog.String("kind", resourceRef.Type.Kind),
+			slog.String("resource", resourceRef.Name),
+			slog.Time("occurredAt", occurredAt),
+			slog.Int64("generation", resource.Metadata.Generation),
+			slog.Time("storedObservedAt", entry.ObservedAt),
+			slog.Int64("storedGeneration", entry.Generation),
+		)
+		return nil
+	}
+
+	if resource.Suspended == entry.Suspended {
+		// Probably something else about the resource modified, but the newer observation is recorded so that any
+		// older events subsequently received are recognised as stale
+		observe(&entry, resource, occurredAt)
+		return w.store.SaveEntry(entry)
 	}
 
-	slog.Info(
+	w.logger.Info(
 		"suspension status updated",
 		slog.String("kind", resourceRef.Type.Kind),
 		slog.String("resource", resourceRef.Name),
-		slog.String("user", updatedBy),
-		slog.Bool("suspended", resource.Spec.Suspend),
+		slog.String("user", updatedBy.String()),
+		slog.Bool("suspended", resource.Suspended),
+		slog.Bool("reconciled", origin == originReconciliation),
 	)
 
 	entry.Resource = resourceRef
-	entry.Suspended = resource.Spec.Suspend
-	entry.UpdatedBy = updatedBy
-	entry.UpdatedAt = time.Now().UTC()
+	entry.Suspended = resource.Suspended
+	entry.UpdatedBy = updatedBy.Principal
+	entry.UpdatedAt = occurredAt
+	entry.Actor = updatedBy
+	observe(&entry, resource, occurredAt)
 
-	if err = w.store.SaveEntry(entry); err != nil {
-		return err
+	// The notification is sent before the state is saved, so that if it fails, the change is still detected when the
+	// event is retried
+	if err = w.notifier.Notify(ctx, notification.Notification{
+		Cluster:              w.cluster,
+		Resource:             entry.Resource,
+		Suspended:            entry.Suspended,
+		Email:                entry.UpdatedBy,
+		Actor:                entry.Actor,
+		GoogleCloudProjectID: w.googleCloudProjectID,
+		Time:                 occurredAt,
+		Reconciled:           origin == originReconciliation,
+	}); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
 	}
 
-	return w.notifier.Notify(ctx, notification.Notification{
+	return w.store.SaveEntry(entry)
+}
+
+// recordObservation records that a tracked resource was modified, without its suspension status changing, so that any
+// older events subsequently received are recognised as stale. The version of the object is not known, so is cleared.
+func (w *Watcher) recordObservation(resourceRef k8s.ResourceReference, occurredAt time.Time) error {
+	entry, err := w.store.GetEntry(resourceRef)
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+		return nil // Untracked, so discovered by the next reconciliation
+	case err != nil:
+		return fmt.Errorf("failed to fetch entry: %w", err)
+	case entry.Tombstoned, entry.IsNewerThan(0, occurredAt):
+		return nil
+	}
+
+	observe(&entry, fluxcd.Resource{}, occurredAt)
+	return w.store.SaveEntry(entry)
+}
+
+// isRecreated returns true if the resource is a new instance of a deleted resource. Instances are identified by their
+// UID; a resource pending deletion (e.g. while its finalizers complete) is the deleted instance. Where the UID of the
+// deleted instance is not known, an object observed after the deletion that is not pending deletion must be a new
+// instance. Where only the request is known, only an operation that created the resource after the deletion is taken
+// to be a recreation.
+func isRecreated(entry datastore.Entry, resource fluxcd.Resource, occurredAt time.Time, created bool) bool {
+	switch {
+	case resource.Metadata.DeletionTimestamp != nil:
+		return false
+	case resource.Metadata.UID != "" && entry.UID != "":
+		return resource.Metadata.UID != entry.UID
+	case resource.Metadata.UID != "":
+		return entry.DeletedAt.Before(occurredAt)
+	default:
+		return created && entry.DeletedAt.Before(occurredAt)
+	}
+}
+
+// processDeletion notifies that a tracked resource has been deleted, along with the suspension status it held, and
+// tombstones its entry. Deletions of resources that were never tracked, or were already deleted, are ignored.
+func (w *Watcher) processDeletion(
+	ctx context.Context,
+	resourceRef k8s.ResourceReference,
+	deletedBy actor.Actor,
+	occurredAt time.Time,
+	origin origin,
+) error {
+	entry, err := w.store.GetEntry(resourceRef)
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+		w.logger.Info(
+			"untracked resource deleted, ignoring",
+			slog.String("kind", resourceRef.Type.Kind),
+			slog.String("resource", resourceRef.Name),
+		)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to fetch entry: %w", err)
+	case entry.Tombstoned:
+		return nil
+	}
+
+	// Generations are not known for deletions, so the observation times are compared
+	if entry.IsNewerThan(0, occurredAt) {
+		w.logger.Warn(
+			"discarding stale deletion",
+			slog.String("kind", resourceRef.Type.Kind),
+			slog.String("resource", resourceRef.Name),
+			slog.Time("occurredAt", occurredAt),
+			slog.Time("storedObservedAt", entry.ObservedAt),
+		)
+		return nil
+	}
+
+	w.logger.Info(
+		"resource deleted",
+		slog.String("kind", resourceRef.Type.Kind),
+		slog.String("resource", resourceRef.Name),
+		slog.String("user", deletedBy.String()),
+		slog.Bool("suspended", entry.Suspended),
+		slog.Bool("reconciled", origin == originReconciliation),
+	)
+
+	entry.Resource = resourceRef
+	entry.UpdatedBy = deletedBy.Principal
+	entry.UpdatedAt = occurredAt
+	entry.Actor = deletedBy
+	entry.ObservedAt = occurredAt
+	entry.Generation = 0
+	entry.ResourceVersion = ""
+	entry.Tombstoned = true
+	entry.DeletedAt = occurredAt
+
+	if err = w.notifier.Notify(ctx, notification.Notification{
+		Cluster:              w.cluster,
 		Resource:             entry.Resource,
 		Suspended:            entry.Suspended,
 		Email:                entry.UpdatedBy,
+		Actor:                entry.Actor,
 		GoogleCloudProjectID: w.googleCloudProjectID,
-	})
+		Time:                 occurredAt,
+		Deleted:              true,
+		Reconciled:           origin == originReconciliation,
+	}); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+
+	return w.store.SaveEntry(entry)
+}
+
+// observe records the observation time, and the version of the object observed, against the entry. The version is
+// cleared if not known (e.g. the event only carries the request), as a version observed earlier no longer describes
+// the stored state. The UID is recorded where known.
+func observe(entry *datastore.Entry, resource fluxcd.Resource, occurredAt time.Time) {
+	entry.ObservedAt = occurredAt
+	if resource.Metadata.UID != "" {
+		entry.UID = resource.Metadata.UID
+	}
+	entry.Generation = resource.Metadata.Generation
+	entry.ResourceVersion = resource.Metadata.ResourceVersion
 }
@@ -0,0 +1,470 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/actor"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/notification"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
+)
+
+var kustomizations = k8s.ResourceType{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Kind: "kustomizations"}
+
+var podinfo = k8s.ResourceReference{Type: kustomizations, Namespace: "flux-system", Name: "podinfo"}
+
+var (
+	alice = actor.Actor{Principal: "alice@acme.com"}
+	bob   = actor.Actor{Principal: "bob@acme.com"}
+)
+
+func TestWatcher_notifiesSuspensionChanges(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, false, false))
+	h := startWatcher(t, client)
+
+	now := time.Now().UTC()
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute), Object: kustomization("uid-1", 2, true, false)})
+	h.publish(source.Event{Resource: podinfo, Actor: bob, Time: now.Add(time.Minute * 2), Request: []byte(`{"spec":{"suspend":false}}`)})
+	h.flush()
+
+	h.assertNotifications(t, "suspended by alice@acme.com", "resumed by bob@acme.com")
+	entry := h.entry(t, podinfo)
+	if entry.Suspended || entry.UpdatedBy != bob.Principal || entry.UID != "uid-1" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestWatcher_discardsStaleEvents(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 5, false, false))
+	h := startWatcher(t, client)
+
+	now := time.Now().UTC()
+	// Only the request is known, so the generation is not
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute * 2), Request: []byte(`{"spec":{"suspend":true}}`)})
+	// Delayed, so older than the stored state despite a newer generation than was listed
+	h.publish(source.Event{Resource: podinfo, Actor: bob, Time: now.Add(time.Minute), Object: kustomization("uid-1", 6, false, false)})
+	h.publish(source.Event{Resource: podinfo, Actor: bob, Time: now.Add(time.Minute * 3), Object: kustomization("uid-1", 8, false, false)})
+	// An older generation, despite a later time
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute * 4), Object: kustomization("uid-1", 7, true, false)})
+	h.flush()
+
+	h.assertNotifications(t, "suspended by alice@acme.com", "resumed by bob@acme.com")
+	if entry := h.entry(t, podinfo); entry.Suspended || entry.Generation != 8 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestWatcher_ignoresRequestsNotChangingSuspension(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, false, false))
+	h := startWatcher(t, client)
+
+	// Suspended by a later operation, which has not been received yet
+	client.set(podinfo, kustomization("uid-1", 3, true, false))
+
+	now := time.Now().UTC()
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute), Request: []byte(`{"metadata":{"labels":{"team":"platform"}}}`)})
+	h.flush()
+
+	h.assertNotifications(t)
+	entry := h.entry(t, podinfo)
+	if entry.Suspended || entry.Generation != 0 || !entry.ObservedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	h.publish(source.Event{Resource: podinfo, Actor: bob, Time: now.Add(time.Minute * 2), Object: kustomization("uid-1", 3, true, false)})
+	h.flush()
+	h.assertNotifications(t, "suspended by bob@acme.com")
+}
+
+func TestWatcher_notifiesDeletion(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, true, false))
+	h := startWatcher(t, client)
+
+	now := time.Now().UTC()
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute), Deleted: true})
+	// The finalizer is removed after deletion
+	h.publish(source.Event{Resource: podinfo, Actor: bob, Time: now.Add(time.Minute * 2), Object: kustomization("uid-1", 1, false, true)})
+	h.flush()
+
+	h.assertNotifications(t, "deleted while suspended by alice@acme.com")
+	if entry := h.entry(t, podinfo); !entry.Tombstoned {
+		t.Errorf("expected tombstoned entry: %+v", entry)
+	}
+
+	// Still listed while the finalizer is pending
+	client.set(podinfo, kustomization("uid-1", 1, false, true))
+	h.reconcile(t)
+	if entry := h.entry(t, podinfo); !entry.Tombstoned {
+		t.Errorf("expected tombstoned entry: %+v", entry)
+	}
+
+	client.delete(podinfo)
+	h.reconcile(t)
+	h.reconcile(t)
+	h.assertNotifications(t, "deleted while suspended by alice@acme.com")
+
+	// Recreated resources are recorded as if discovered
+	client.set(podinfo, kustomization("uid-2", 1, false, false))
+	h.reconcile(t)
+	h.assertNotifications(t, "deleted while suspended by alice@acme.com")
+	if entry := h.entry(t, podinfo); entry.Tombstoned || entry.Suspended || entry.UID != "uid-2" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestWatcher_detectsDeletionByReconciliation(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, false, false))
+	h := startWatcher(t, client)
+
+	client.delete(podinfo)
+	h.reconcile(t)
+	h.assertNotifications(t) // Deferred until the next reconciliation
+	h.reconcile(t)
+	h.assertNotifications(t, "deleted while active (detected by reconciliation)")
+}
+
+func TestWatcher_retriesTransientFailures(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, false, false))
+	h := startWatcher(t, client)
+	h.notifier.fail(1)
+
+	now := time.Now().UTC()
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: now.Add(time.Minute), Object: kustomization("uid-1", 2, true, false)})
+	h.flush()
+
+	h.assertNotifications(t)
+	retries, err := h.store.DueRetries(now.Add(retryMaxBackoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retries) != 1 || retries[0].Attempts != 1 {
+		t.Fatalf("unexpected retries: %+v", retries)
+	}
+	if entry := h.entry(t, podinfo); entry.Suspended {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	if err = h.watcher.isolate(context.Background(), retries[0].Event, &retries[0]); err != nil {
+		t.Fatal(err)
+	}
+	h.assertNotifications(t, "suspended by alice@acme.com")
+	if retries, _ = h.store.DueRetries(now.Add(retryMaxBackoff)); len(retries) != 0 {
+		t.Errorf("unexpected retries: %+v", retries)
+	}
+	if len(h.store.failures) != 0 {
+		t.Errorf("unexpected failures: %+v", h.store.failures)
+	}
+}
+
+func TestWatcher_recordsPermanentFailures(t *testing.T) {
+	client := newFakeK8sClient()
+	client.set(podinfo, kustomization("uid-1", 1, false, false))
+	h := startWatcher(t, client)
+
+	h.publish(source.Event{Resource: podinfo, Actor: alice, Time: time.Now().UTC(), Object: []byte("{")})
+	h.flush()
+
+	if len(h.store.failures) != 1 || h.store.failures[0].Attempts != 1 {
+		t.Errorf("unexpected failures: %+v", h.store.failures)
+	}
+	if retries, _ := h.store.DueRetries(time.Now().Add(retryMaxBackoff)); len(retries) != 0 {
+		t.Errorf("unexpected retries: %+v", retries)
+	}
+}
+
+// harness runs a watcher against a memory source, until the test completes
+type harness struct {
+	watcher  *Watcher
+	src      *source.Memory
+	store    *fakeStore
+	notifier *fakeNotifier
+}
+
+func startWatcher(t *testing.T, client *fakeK8sClient) *harness {
+	t.Helper()
+
+	h := &harness{
+		src:      source.NewMemory(0),
+		store:    newFakeStore(),
+		notifier: &fakeNotifier{},
+	}
+	h.watcher = NewWatcher("test", "", h.src, nil, nil, nil, client, h.store, h.notifier, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.watcher.Watch(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errCh; !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	// The source is only run once initialization is complete
+	h.flush()
+	return h
+}
+
+// publish delivers an event to the watcher. Events are handled in order, so an event has been handled once the next
+// has been delivered.
+func (h *harness) publish(event source.Event) {
+	_ = h.src.Publish(context.Background(), event)
+}
+
+// flush waits for the events published so far to be handled, by publishing an event that is ignored
+func (h *harness) flush() {
+	h.publish(source.Event{Resource: k8s.ResourceReference{Type: k8s.ResourceType{Kind: "ignored"}}})
+}
+
+func (h *harness) reconcile(t *testing.T) {
+	t.Helper()
+	if err := h.watcher.reconcile(context.Background(), h.watcher.watchedTypes(), true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (h *harness) entry(t *testing.T, ref k8s.ResourceReference) datastore.Entry {
+	t.Helper()
+	entry, err := h.store.GetEntry(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func (h *harness) assertNotifications(t *testing.T, want ...string) {
+	t.Helper()
+	got := h.notifier.summaries()
+	if !slices.Equal(got, want) {
+		t.Errorf("notifications = %q, want %q", got, want)
+	}
+}
+
+func kustomization(uid string, generation int64, suspended bool, deleting bool) []byte {
+	metadata := map[string]any{
+		"name":       podinfo.Name,
+		"namespace":  podinfo.Namespace,
+		"uid":        uid,
+		"generation": generation,
+	}
+	if deleting {
+		metadata["deletionTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	data, _ := json.Marshal(map[string]any{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+		"kind":       "Kustomization",
+		"metadata":   metadata,
+		"spec":       map[string]any{"suspend": suspended},
+	})
+	return data
+}
+
+type fakeK8sClient struct {
+	mu        sync.Mutex
+	resources map[k8s.ResourceReference][]byte
+}
+
+func newFakeK8sClient() *fakeK8sClient {
+	return &fakeK8sClient{
+		resources: make(map[k8s.ResourceReference][]byte),
+	}
+}
+
+func (c *fakeK8sClient) set(ref k8s.ResourceReference, object []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resources[ref] = object
+}
+
+func (c *fakeK8sClient) delete(ref k8s.ResourceReference) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.resources, ref)
+}
+
+func (c *fakeK8sClient) GetRawResource(_ context.Context, ref k8s.ResourceReference) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	object, ok := c.resources[ref]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: ref.Type.Group, Resource: ref.Type.Kind}, ref.Name)
+	}
+	return object, nil
+}
+
+func (c *fakeK8sClient) GetRawResources(_ context.Context, t k8s.ResourceType) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items := make([]json.RawMessage, 0, len(c.resources))
+	for ref, object := range c.resources {
+		if ref.Type.Group == t.Group && ref.Type.Kind == t.Kind {
+			items = append(items, object)
+		}
+	}
+	return json.Marshal(map[string]any{"items": items})
+}
+
+func (c *fakeK8sClient) GetCustomResourceDefinitions(context.Context, metav1.ListOptions) (*v1.CustomResourceDefinitionList, error) {
+	suspendable := &v1.CustomResourceValidation{
+		OpenAPIV3Schema: &v1.JSONSchemaProps{
+			Properties: map[string]v1.JSONSchemaProps{
+				"spec": {Properties: map[string]v1.JSONSchemaProps{"suspend": {Type: "boolean"}}},
+			},
+		},
+	}
+	return &v1.CustomResourceDefinitionList{
+		Items: []v1.CustomResourceDefinition{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   fmt.Sprintf("%s.%s", kustomizations.Kind, kustomizations.Group),
+					Labels: map[string]string{"app.kubernetes.io/part-of": "flux"},
+				},
+				Spec: v1.CustomResourceDefinitionSpec{
+					Group: kustomizations.Group,
+					Names: v1.CustomResourceDefinitionNames{Plural: kustomizations.Kind, Kind: "Kustomization"},
+					Versions: []v1.CustomResourceDefinitionVersion{
+						{Name: kustomizations.Version, Schema: suspendable},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (c *fakeK8sClient) WatchCustomResourceDefinitions(ctx context.Context, _ string, _ func()) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *fakeK8sClient) ServesResourceType(k8s.ResourceType) (bool, error) {
+	return false, nil
+}
+
+type fakeStore struct {
+	mu       sync.Mutex
+	entries  map[k8s.ResourceReference]datastore.Entry
+	retries  map[string]datastore.Retry
+	failures []datastore.Failure
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		entries: make(map[k8s.ResourceReference]datastore.Entry),
+		retries: make(map[string]datastore.Retry),
+	}
+}
+
+func (s *fakeStore) GetEntry(ref k8s.ResourceReference) (datastore.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[ref]
+	if !ok {
+		return datastore.Entry{}, datastore.ErrNotFound
+	}
+	return entry, nil
+}
+
+func (s *fakeStore) SaveEntry(entry datastore.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Resource] = entry
+	return nil
+}
+
+func (s *fakeStore) Entries() ([]datastore.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]datastore.Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *fakeStore) SaveRetry(retry datastore.Retry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries[retry.ID] = retry
+	return nil
+}
+
+func (s *fakeStore) DeleteRetry(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.retries, id)
+	return nil
+}
+
+func (s *fakeStore) DueRetries(now time.Time) ([]datastore.Retry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var retries []datastore.Retry
+	for _, retry := range s.retries {
+		if !retry.NextAttempt.After(now) {
+			retries = append(retries, retry)
+		}
+	}
+	return retries, nil
+}
+
+func (s *fakeStore) SaveFailure(failure datastore.Failure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure)
+	return nil
+}
+
+type fakeNotifier struct {
+	mu            sync.Mutex
+	notifications []notification.Notification
+	failures      int
+}
+
+// fail causes the next n notifications to fail
+func (n *fakeNotifier) fail(failures int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures = failures
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, notif notification.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("slack unavailable")
+	}
+	n.notifications = append(n.notifications, notif)
+	return nil
+}
+
+func (n *fakeNotifier) summaries() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	summaries := make([]string, 0, len(n.notifications))
+	for _, notif := range n.notifications {
+		summaries = append(summaries, notif.Summary())
+	}
+	return summaries
+}
@@ -5,71 +5,376 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
+	"golang.org/x/sync/errgroup"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditfilter"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditlog"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/cloudwatch"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/config"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/discovery"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/fluxcd"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/informer"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/k8s"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kafka"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/kubeaudit"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/metrics"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/notification"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/opensearch"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/source"
 	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/watch"
 )
 
+const (
+	// watchBaseBackoff and watchMaxBackoff bound the delay before watching a cluster again, after a failure
+	watchBaseBackoff = time.Second * 5
+	watchMaxBackoff  = time.Minute * 5
+	// watchStableAfter is how long a cluster must have been watched for, before a failure no longer counts towards the
+	// backoff
+	watchStableAfter = time.Minute * 10
+)
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	if err := run(ctx); err != nil {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		err = replay(ctx, os.Args[2:])
+	} else {
+		err = run(ctx)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 func run(ctx context.Context) error {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		return errors.New("config path environment variable not set")
+	conf, err := loadConfig()
+	if err != nil {
+		return err
 	}
 
-	conf, err := config.ParseFile(configPath)
+	store, err := datastore.NewBadgerStore(conf.BadgerPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %w", err)
+		return err
 	}
+	defer store.Close()
 
-	k8sClient, err := k8s.NewClient(conf.KubernetesConfigPath)
+	notifier, err := newNotifier(conf)
 	if err != nil {
 		return err
 	}
 
-	store, err := datastore.NewBadgerStore(conf.BadgerPath)
+	// Every watcher is created before any are started, so that nothing is left running against the store if one fails
+	watchers := make([]*watch.Watcher, 0, len(conf.Clusters))
+	for _, cluster := range conf.Clusters {
+		watcher, err := newWatcher(cluster, store.ForCluster(cluster.Name), notifier)
+		if err != nil {
+			return fmt.Errorf("failed to create watcher for cluster %s: %w", cluster.DisplayName(), err)
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	if conf.MetricsListenAddress != "" {
+		g.Go(func() error {
+			return metrics.Serve(ctx, conf.MetricsListenAddress)
+		})
+	}
+	for i, cluster := range conf.Clusters {
+		g.Go(func() error {
+			watchCluster(ctx, cluster, watchers[i])
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// watchCluster watches a single cluster until the context is cancelled. Failures (e.g. an unreachable API server) are
+// logged, and the cluster is watched again after a backoff, so that they do not affect other clusters.
+func watchCluster(ctx context.Context, cluster config.Cluster, watcher *watch.Watcher) {
+	logger := slog.With(slog.String("cluster", cluster.DisplayName()))
+
+	var failures int
+	for {
+		startedAt := time.Now()
+		err := watcher.Watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(startedAt) >= watchStableAfter {
+			failures = 0
+		}
+		failures++
+
+		backoff := watchBaseBackoff << (failures - 1)
+		if backoff <= 0 || backoff > watchMaxBackoff {
+			backoff = watchMaxBackoff
+		}
+		logger.Error("watch failed, will retry", slog.Any("error", err), slog.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// loadConfig parses the configuration file pointed at by the CONFIG_PATH environment variable
+func loadConfig() (config.Config, error) {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		return config.Config{}, errors.New("config path environment variable not set")
+	}
+
+	conf, err := config.ParseFile(configPath)
 	if err != nil {
-		return err
+		return config.Config{}, fmt.Errorf("failed to parse config: %w", err)
 	}
-	defer store.Close()
+	return conf, nil
+}
 
+// newNotifier instantiates the configured notifiers
+func newNotifier(conf config.Config) (notification.Notifier, error) {
 	notifiers := make([]notification.Notifier, 0, len(conf.Notification.Slack))
 	for _, slack := range conf.Notification.Slack {
-		var notifier notification.Notifier
-		notifier, err = notification.NewSlackNotifier(slack.WebhookURL)
+		notifier, err := notification.NewSlackNotifier(slack.WebhookURL)
 		if err != nil {
-			return fmt.Errorf("failed to create slack notifier: %w", err)
+			return nil, fmt.Errorf("failed to create slack notifier: %w", err)
 		}
-		if slack.Filter != "" {
-			notifier, err = notification.NewFilteringNotifier(slack.Filter, notifier)
-			if err != nil {
-				return fmt.Errorf("failed to create filtering notifier: %w", err)
-			}
+		if slack.Filter == "" {
+			notifiers = append(notifiers, notifier)
+			continue
 		}
-		notifiers = append(notifiers, notifier)
+		filteringNotifier, err := notification.NewFilteringNotifier(slack.Filter, notifier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create filtering notifier: %w", err)
+		}
+		notifiers = append(notifiers, filteringNotifier)
+	}
+	return notification.NewMultiNotifier(notifiers), nil
+}
+
+// newWatcher instantiates a watcher for a single cluster
+func newWatcher(cluster config.Cluster, store *datastore.Store, notifier notification.Notifier) (*watch.Watcher, error) {
+	k8sClient, err := k8s.NewClient(cluster.KubernetesConfigPath, cluster.KubernetesContext)
+	if err != nil {
+		return nil, err
+	}
+
+	discoverySelector, err := newDiscoverySelector(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := suspendableDefinitions(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newAuditFilter(cluster, discoverySelector, definitions)
+	if err != nil {
+		return nil, err
 	}
 
-	watcher := watch.NewWatcher(
-		conf.GoogleCloudProjectID,
-		conf.GKEClusterName,
+	src, err := newSource(cluster, filter, definitions, k8sClient, store)
+	if err != nil {
+		return nil, err
+	}
+
+	return watch.NewWatcher(
+		cluster.DisplayName(),
+		cluster.GoogleCloudProjectID,
+		src,
+		discoverySelector,
+		filter,
+		definitions,
 		k8sClient,
 		store,
-		notification.NewMultiNotifier(notifiers),
+		notifier,
+		cluster.ReconcileInterval,
+	), nil
+}
+
+// newDiscoverySelector builds the selector of the resource types to discover
+func newDiscoverySelector(conf config.Cluster) (*discovery.Selector, error) {
+	selector, err := discovery.New(
+		conf.Discovery.LabelSelectors,
+		conf.Discovery.APIGroups,
+		conf.Discovery.Include,
+		conf.Discovery.Exclude,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discovery: %w", err)
+	}
+	return selector, nil
+}
+
+// suspendableDefinitions builds the definitions of the suspendable resource types declared by the configuration
+func suspendableDefinitions(conf config.Cluster) ([]fluxcd.Definition, error) {
+	definitions := make([]fluxcd.Definition, 0, len(conf.SuspendableTypes))
+	for _, st := range conf.SuspendableTypes {
+		def, err := fluxcd.NewDefinition(
+			k8s.ResourceType{Group: st.Group, Version: st.Version, Kind: st.Resource},
+			st.Path,
+			st.Annotation,
+			st.SuspendedValue,
+			st.SuspendedWhenAbsent,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid suspendable type %s/%s: %w", st.Group, st.Resource, err)
+		}
+		definitions = append(definitions, def)
+	}
+	return definitions, nil
+}
 
-	return watcher.Watch(ctx)
+// newAuditFilter builds the audit filter applied by the source. Audit events are filtered to the API groups selected
+// for discovery, and those of the declared suspendable types, in addition to those configured explicitly. The groups
+// of types selected only by label are added by the watcher, once discovered.
+func newAuditFilter(
+	conf config.Cluster,
+	discoverySelector *discovery.Selector,
+	definitions []fluxcd.Definition,
+) (*auditfilter.Filter, error) {
+	apiGroups := discoverySelector.APIGroups()
+	for _, def := range definitions {
+		if !slices.Contains(apiGroups, def.Type.Group) {
+			apiGroups = append(apiGroups, def.Type.Group)
+		}
+	}
+
+	filter, err := auditfilter.New(
+		conf.AuditFilter.Verbs,
+		conf.AuditFilter.ExcludedPrincipals,
+		conf.AuditFilter.IncludedPrincipals,
+		append(apiGroups, conf.AuditFilter.APIGroups...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit filter: %w", err)
+	}
+	return filter, nil
+}
+
+// newSource instantiates the audit event source selected by the configuration, applying the supplied audit filter
+func newSource(
+	conf config.Cluster,
+	filter *auditfilter.Filter,
+	definitions []fluxcd.Definition,
+	k8sClient *k8s.Client,
+	store *datastore.Store,
+) (source.Source, error) {
+	var err error
+	gkeCluster := auditlog.Cluster{
+		ProjectID:   conf.GoogleCloudProjectID,
+		Name:        conf.GKEClusterName,
+		DisplayName: conf.DisplayName(),
+		LogBucket:   conf.LogBucket,
+		Filter:      filter,
+		Client: auditlog.ClientConfig{
+			CredentialsFile:           conf.CloudLogging.CredentialsFile,
+			ImpersonateServiceAccount: conf.CloudLogging.ImpersonateServiceAccount,
+			QuotaProject:              conf.CloudLogging.QuotaProject,
+			Endpoint:                  conf.CloudLogging.Endpoint,
+			Insecure:                  conf.CloudLogging.Insecure,
+		},
+	}
+	if conf.Source == config.SourceGKE || conf.Source == config.SourcePubSub {
+		if err = gkeCluster.Client.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid cloud logging settings: %w", err)
+		}
+	}
+
+	switch conf.Source {
+	case config.SourceGKE:
+		switch conf.CloudLogging.Mode {
+		case config.CloudLoggingModeStream:
+			return auditlog.NewSource(gkeCluster, store), nil
+		case config.CloudLoggingModePoll:
+			return auditlog.NewPollingSource(
+				gkeCluster,
+				store,
+				conf.CloudLogging.PollInterval,
+				conf.CloudLogging.PageSize,
+			), nil
+		default:
+			return nil, fmt.Errorf("unsupported cloud logging mode: %s", conf.CloudLogging.Mode)
+		}
+	case config.SourcePubSub:
+		return auditlog.NewPubSubSource(gkeCluster, store, conf.PubSub.ProjectID, conf.PubSub.SubscriptionID), nil
+	case config.SourceAuditWebhook:
+		webhookConfig := kubeaudit.WebhookConfig{
+			ListenAddress: conf.AuditWebhook.ListenAddress,
+			Path:          conf.AuditWebhook.Path,
+			CertFile:      conf.AuditWebhook.CertFile,
+			KeyFile:       conf.AuditWebhook.KeyFile,
+			ClientCAFile:  conf.AuditWebhook.ClientCAFile,
+		}
+		if err = webhookConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid audit webhook settings: %w", err)
+		}
+		return kubeaudit.NewWebhookSource(webhookConfig, filter), nil
+	case config.SourceAuditFile:
+		return kubeaudit.NewFileSource(conf.AuditFile.Path, store, filter), nil
+	case config.SourceEKS:
+		clusterName := conf.EKS.ClusterName
+		if clusterName == "" {
+			clusterName = conf.DisplayName()
+		}
+		if clusterName == "" && conf.EKS.LogGroupName == "" {
+			return nil, errors.New("eks cluster name or log group name must be supplied")
+		}
+		return cloudwatch.NewSource(cloudwatch.Config{
+			ClusterName:  clusterName,
+			LogGroupName: conf.EKS.LogGroupName,
+			Region:       conf.EKS.Region,
+			Endpoint:     conf.EKS.Endpoint,
+			PollInterval: conf.EKS.PollInterval,
+		}, store, filter), nil
+	case config.SourceKafka:
+		kafkaConfig := kafka.Config{
+			Brokers:       conf.Kafka.Brokers,
+			Topic:         conf.Kafka.Topic,
+			GroupID:       conf.Kafka.GroupID,
+			TLS:           conf.Kafka.TLS,
+			SASLMechanism: conf.Kafka.SASL.Mechanism,
+			Username:      conf.Kafka.SASL.Username,
+			Password:      conf.Kafka.SASL.Password,
+		}
+		if err = kafkaConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid kafka settings: %w", err)
+		}
+		return kafka.NewSource(kafkaConfig, filter), nil
+	case config.SourceOpenSearch:
+		openSearchConfig := opensearch.Config{
+			URL:                conf.OpenSearch.URL,
+			Username:           conf.OpenSearch.Username,
+			Password:           conf.OpenSearch.Password,
+			InsecureSkipVerify: conf.OpenSearch.InsecureSkipVerify,
+			Index:              conf.OpenSearch.Index,
+			Query:              conf.OpenSearch.Query,
+			EventField:         conf.OpenSearch.EventField,
+			TimestampField:     conf.OpenSearch.TimestampField,
+			TiebreakerField:    conf.OpenSearch.TiebreakerField,
+			PageSize:           conf.OpenSearch.PageSize,
+			PollInterval:       conf.OpenSearch.PollInterval,
+		}
+		if err = openSearchConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid opensearch settings: %w", err)
+		}
+		return opensearch.NewSource(openSearchConfig, store, filter), nil
+	case config.SourceInformer:
+		return informer.NewSource(k8sClient.DynamicClient(), definitions), nil
+	default:
+		return nil, fmt.Errorf("unsupported source: %s", conf.Source)
+	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/auditlog"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/config"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/datastore"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/fluxcd"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/notification"
+	"github.com/e-flux-platform/fluxcd-suspend-notifier/internal/watch"
+)
+
+// replay reconstructs suspension history from audit log entries exported from Cloud Logging. Entries are processed
+// against a scratch store, and the resulting notifications are printed, or optionally dispatched via the configured
+// notifiers. Where a configuration is supplied, entries are filtered as configured for the cluster.
+func replay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = flags.Output().Write([]byte("Usage: fluxcd-suspend-notifier replay [flags] FILE...\n"))
+		flags.PrintDefaults()
+	}
+	projectID := flags.String("project", "", "only replay entries from this Google Cloud project")
+	clusterName := flags.String("cluster", "", "only replay entries from this GKE cluster")
+	dispatch := flags.Bool("dispatch", false, "dispatch notifications via the notifiers configured at CONFIG_PATH, rather than printing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no export files supplied")
+	}
+
+	var (
+		notifier    notification.Notifier = notification.NewWriterNotifier(os.Stdout)
+		cluster                           = auditlog.Cluster{ProjectID: *projectID, Name: *clusterName}
+		definitions []fluxcd.Definition
+	)
+	if *dispatch || os.Getenv("CONFIG_PATH") != "" {
+		conf, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if *dispatch {
+			if notifier, err = newNotifier(conf); err != nil {
+				return err
+			}
+		}
+
+		clusterConf, err := replayCluster(conf, *clusterName)
+		if err != nil {
+			return err
+		}
+		if *clusterName != "" && clusterConf.GKEClusterName != "" {
+			cluster.Name = clusterConf.GKEClusterName // The cluster may have been identified by its name
+		}
+		discoverySelector, err := newDiscoverySelector(clusterConf)
+		if err != nil {
+			return err
+		}
+		if definitions, err = suspendableDefinitions(clusterConf); err != nil {
+			return err
+		}
+		if cluster.Filter, err = newAuditFilter(clusterConf, discoverySelector, definitions); err != nil {
+			return err
+		}
+	}
+
+	store, err := datastore.NewInMemoryBadgerStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	src := auditlog.NewReplaySource(cluster, flags.Args())
+
+	// The kubernetes API is not consulted when replaying
+	watcher := watch.NewWatcher(*clusterName, *projectID, src, nil, nil, definitions, nil, store, notifier, 0)
+
+	return watcher.Replay(ctx)
+}
+
+// replayCluster returns the configuration of the cluster being replayed, which is identified by its GKE cluster name
+// or its name. The cluster need not be identified if only one is configured.
+func replayCluster(conf config.Config, clusterName string) (config.Cluster, error) {
+	if clusterName == "" {
+		if len(conf.Clusters) == 1 {
+			return conf.Clusters[0], nil
+		}
+		return config.Cluster{}, errors.New("-cluster must be supplied to select the configured cluster to replay")
+	}
+	for _, cluster := range conf.Clusters {
+		if cluster.GKEClusterName == clusterName || cluster.DisplayName() == clusterName {
+			return cluster, nil
+		}
+	}
+	return config.Cluster{}, fmt.Errorf("cluster %s is not configured", clusterName)
+}